@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -31,7 +32,29 @@ func GetDeviceList(c *fritzbox.Client) (*DeviceList, error) {
 	}
 	defer resp.Body.Close()
 
-	return deviceListFromReader(resp.Body)
+	dl, err := deviceListFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	dl.logUnknown(c.Logger())
+	return dl, nil
+}
+
+// logUnknown logs HAN-FUN units whose interface is not implemented.
+// Their values are still available via RawProperties.
+func (dl *DeviceList) logUnknown(l *slog.Logger) {
+	for _, d := range dl.Devices {
+		hf, ok := d.Capabilities[CHanfun].(*HanFun)
+		if !ok {
+			continue
+		}
+		for _, u := range hf.Units {
+			if u.Interface == nil {
+				l.Debug("unknown HAN-FUN interface", "identifier", u.Device().Identifier,
+					"interface", u.ETSIUnitInfo.Interface, "unittype", u.ETSIUnitInfo.UnitType)
+			}
+		}
+	}
 }
 
 func GetDeviceListFilter(c *fritzbox.Client, cap Capability) (dl *DeviceList, err error) {
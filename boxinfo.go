@@ -0,0 +1,322 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotSupported is returned when the firmware of the box lacks a feature.
+var ErrNotSupported = errors.New("not supported by this FRITZ!OS version")
+
+// boxInfoPath is readable without login on all boxes since FRITZ!OS 5.
+const boxInfoPath = "jason_boxinfo.xml"
+
+// Version is a FRITZ!OS version such as 7.57 or 8.20.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses versions as reported by the box, with or without the leading
+// hardware code and a trailing lab build number, e.g. "154.07.57", "07.57" or
+// "171.08.21-117321".
+func ParseVersion(s string) (Version, error) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "-")
+	parts := strings.Split(s, ".")
+	if len(parts) == 3 {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return Version{}, fmt.Errorf("invalid FRITZ!OS version %q", s)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid FRITZ!OS version %q", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid FRITZ!OS version %q", s)
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// AtLeast reports whether v is the same as or newer than min.
+func (v Version) AtLeast(min Version) bool {
+	if v.Major != min.Major {
+		return v.Major > min.Major
+	}
+	return v.Minor >= min.Minor
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%02d", v.Major, v.Minor)
+}
+
+// BoxInfo describes the hardware and firmware of a box.
+type BoxInfo struct {
+	// Model is the product name, e.g. "FRITZ!Box 7590".
+	Model string
+	// Hardware is AVM's hardware code, also the first part of Firmware.
+	Hardware string
+	// Version is the FRITZ!OS version.
+	Version Version
+	// Firmware is the full firmware version as reported, e.g. "154.07.57".
+	Firmware string
+	Revision string
+	Serial   string
+	OEM      string
+	Language string
+	Country  string
+	Annex    string
+	// Lab is true for lab (beta) firmware.
+	Lab bool
+	// Flags are the feature flags reported by the box, e.g. "mesh_master".
+	Flags []string
+}
+
+// HasFlag reports whether the box reports the feature flag.
+func (b *BoxInfo) HasFlag(flag string) bool {
+	for _, f := range b.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// boxInfoResponse is the content of jason_boxinfo.xml.
+type boxInfoResponse struct {
+	Name     string   `xml:"Name"`
+	HW       string   `xml:"HW"`
+	Version  string   `xml:"Version"`
+	Revision string   `xml:"Revision"`
+	Serial   string   `xml:"Serial"`
+	OEM      string   `xml:"OEM"`
+	Lang     string   `xml:"Lang"`
+	Annex    string   `xml:"Annex"`
+	Lab      *string  `xml:"Lab"`
+	Country  string   `xml:"Country"`
+	Flags    []string `xml:"Flag"`
+}
+
+// Feature is a part of the box's interface that is only available on some
+// FRITZ!OS versions.
+type Feature string
+
+const (
+	// FeatureAHA is the AHA HTTP interface used by the aha package.
+	FeatureAHA Feature = "AHA HTTP interface"
+	// FeaturePBKDF2Login is the PBKDF2 challenge of login_sid.lua.
+	FeaturePBKDF2Login Feature = "PBKDF2 login"
+	// FeatureDataLua are the JSON answers of data.lua used by the unsafe package.
+	FeatureDataLua Feature = "data.lua JSON pages"
+	// FeatureSmartHomeREST is the smart home REST API used by the rest and smart packages.
+	FeatureSmartHomeREST Feature = "smart home REST API"
+)
+
+// featureVersions holds the first FRITZ!OS version offering each feature.
+var featureVersions = map[Feature]Version{
+	FeatureAHA:           {5, 53},
+	FeaturePBKDF2Login:   {7, 24},
+	FeatureDataLua:       {7, 0},
+	FeatureSmartHomeREST: {8, 20},
+}
+
+// pathFeatures maps request paths to the feature needed to use them.
+var pathFeatures = []struct {
+	prefix  string
+	feature Feature
+}{
+	{"api/v0/smarthome/", FeatureSmartHomeREST},
+	{"webservices/homeautoswitch.lua", FeatureAHA},
+	{"data.lua", FeatureDataLua},
+}
+
+// BoxInfo returns model, firmware and feature flags of the box. It does not need a
+// login. While connected, it is fetched once and kept until Close; before Connect, it
+// is fetched from the address URL returns on every call.
+func (c *Client) BoxInfo() (*BoxInfo, error) {
+	return c.BoxInfoContext(c.Context())
+}
+
+// BoxInfoContext is like BoxInfo but uses ctx for the request.
+func (c *Client) BoxInfoContext(ctx context.Context) (*BoxInfo, error) {
+	c = c.root()
+	c.mu.RLock()
+	info := c.boxInfo
+	c.mu.RUnlock()
+	if info != nil {
+		return info, nil
+	}
+
+	cn, err := c.snapshot()
+	connected := err == nil
+	if errors.Is(err, ErrNotConnected) {
+		cn, err = c.unconnectedSnapshot()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err = c.fetchBoxInfo(ctx, cn)
+	if err != nil && ctx.Err() == nil {
+		// boxes with a restricted web UI don't serve jason_boxinfo.xml
+		var lerr error
+		if info, lerr = c.fetchLoginPageInfo(ctx, cn); lerr != nil {
+			return nil, fmt.Errorf("box info: %w", errors.Join(err, lerr))
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if connected {
+		c.mu.Lock()
+		c.boxInfo = info
+		c.mu.Unlock()
+	}
+	return info, nil
+}
+
+func (c *Client) fetchBoxInfo(ctx context.Context, cn conn) (*BoxInfo, error) {
+	resp, err := c.doAha(ctx, cn, http.MethodGet, boxInfoPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r boxInfoResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", boxInfoPath, err)
+	}
+	v, err := ParseVersion(r.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoxInfo{
+		Model:    r.Name,
+		Hardware: r.HW,
+		Version:  v,
+		Firmware: r.Version,
+		Revision: r.Revision,
+		Serial:   r.Serial,
+		OEM:      r.OEM,
+		Language: r.Lang,
+		Country:  r.Country,
+		Annex:    r.Annex,
+		Lab:      r.Lab != nil,
+		Flags:    r.Flags,
+	}, nil
+}
+
+// The login page embeds product name, version and language as JSON.
+var (
+	loginPageModel   = regexp.MustCompile(`"Productname"\s*:\s*"([^"]+)"`)
+	loginPageVersion = regexp.MustCompile(`"nspver"\s*:\s*"([0-9.]+)"`)
+	loginPageLang    = regexp.MustCompile(`"language"\s*:\s*"([a-z]+)"`)
+)
+
+// fetchLoginPageInfo reads model and version from the login page. It is less
+// complete than jason_boxinfo.xml and only used as a fallback.
+func (c *Client) fetchLoginPageInfo(ctx context.Context, cn conn) (*BoxInfo, error) {
+	resp, err := c.doAha(ctx, cn, http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read login page: %w", err)
+	}
+
+	m := loginPageVersion.FindSubmatch(b)
+	if m == nil {
+		return nil, errors.New("no version on login page")
+	}
+	v, err := ParseVersion(string(m[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BoxInfo{Version: v, Firmware: string(m[1])}
+	if m := loginPageModel.FindSubmatch(b); m != nil {
+		info.Model = string(m[1])
+	}
+	if m := loginPageLang.FindSubmatch(b); m != nil {
+		info.Language = string(m[1])
+	}
+	return info, nil
+}
+
+// Supports reports whether the firmware of the box offers the feature.
+func (c *Client) Supports(f Feature) (bool, error) {
+	err := c.Require(f)
+	if errors.Is(err, ErrNotSupported) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Require returns an error wrapping ErrNotSupported if the firmware of the box
+// lacks the feature, naming the version needed:
+//
+//	if err := client.Require(fritzbox.FeatureSmartHomeREST); err != nil {
+//	    // fall back to the aha package
+//	}
+func (c *Client) Require(f Feature) error {
+	return c.requireContext(c.Context(), f)
+}
+
+func (c *Client) requireContext(ctx context.Context, f Feature) error {
+	min, ok := featureVersions[f]
+	if !ok {
+		return fmt.Errorf("unknown feature %q", f)
+	}
+	info, err := c.BoxInfoContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !info.Version.AtLeast(min) {
+		return fmt.Errorf("%w: %s needs FRITZ!OS %s, %s runs %s", ErrNotSupported, f, min, info.Model, info.Version)
+	}
+	return nil
+}
+
+// SetFeatureCheck enables or disables checking the firmware before each request.
+// When enabled, requests to the smart home REST API, the AHA interface and data.lua
+// fail with an error wrapping ErrNotSupported if the box's FRITZ!OS is too old for
+// them, instead of with whatever the box answers. The box info is fetched with the
+// first checked request.
+func (c *Client) SetFeatureCheck(enabled bool) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkFeatures = enabled
+}
+
+// checkFeature checks the feature needed for path if feature checking is enabled.
+func (c *Client) checkFeature(ctx context.Context, path string) error {
+	c.mu.RLock()
+	enabled := c.checkFeatures
+	c.mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+
+	path = strings.TrimPrefix(path, "/")
+	for _, pf := range pathFeatures {
+		if strings.HasPrefix(path, pf.prefix) {
+			return c.requireContext(ctx, pf.feature)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Package callmonitor receives live call events from the call monitor of the
+// FRITZ!Box on TCP port 1012. The monitor is off by default; enable it by dialing
+// #96*5* on a phone connected to the box (#96*4* disables it).
+package callmonitor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+const port = "1012"
+
+// EventType is the kind of a call monitor event.
+type EventType string
+
+const (
+	// Ring is an incoming call ringing.
+	Ring EventType = "RING"
+	// Call is an outgoing call being dialed.
+	Call EventType = "CALL"
+	// Connect is a call being answered.
+	Connect EventType = "CONNECT"
+	// Disconnect is a call ending, answered or not.
+	Disconnect EventType = "DISCONNECT"
+)
+
+// Event is a line of the call monitor. Which fields are set depends on Type:
+//
+//	RING:       Caller, Called, Line
+//	CALL:       Extension, Caller (own number), Called, Line
+//	CONNECT:    Extension, Number (the other party)
+//	DISCONNECT: Duration
+//
+// Events of one call share the ConnectionID, which the box reuses after the call.
+type Event struct {
+	Time         time.Time
+	Type         EventType
+	ConnectionID int
+	Extension    string
+	Caller       string
+	Called       string
+	Number       string
+	// Line is the outgoing line, e.g. "SIP0".
+	Line     string
+	Duration time.Duration
+}
+
+// Parse parses a line of the call monitor, e.g.
+// "16.10.26 18:42:10;RING;0;0301234567;987654;SIP0;". Times are in the local time zone.
+func Parse(line string) (Event, error) {
+	f := strings.Split(strings.TrimRight(strings.TrimSpace(line), ";"), ";")
+	if len(f) < 4 {
+		return Event{}, fmt.Errorf("invalid call monitor line %q", line)
+	}
+
+	t, err := time.ParseInLocation("02.01.06 15:04:05", f[0], time.Local)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid call monitor time %q", f[0])
+	}
+	id, err := strconv.Atoi(f[2])
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid connection id %q", f[2])
+	}
+	ev := Event{Time: t, Type: EventType(f[1]), ConnectionID: id}
+
+	field := func(i int) string {
+		if i < len(f) {
+			return f[i]
+		}
+		return ""
+	}
+	switch ev.Type {
+	case Ring:
+		ev.Caller, ev.Called, ev.Line = field(3), field(4), field(5)
+	case Call:
+		ev.Extension, ev.Caller, ev.Called, ev.Line = field(3), field(4), field(5), field(6)
+	case Connect:
+		ev.Extension, ev.Number = field(3), field(4)
+	case Disconnect:
+		secs, err := strconv.Atoi(f[3])
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid call duration %q", f[3])
+		}
+		ev.Duration = time.Duration(secs) * time.Second
+	default:
+		return Event{}, fmt.Errorf("unknown call monitor event %q", f[1])
+	}
+	return ev, nil
+}
+
+// Monitor connects to the call monitor and reconnects when the connection drops,
+// e.g. while the box reboots.
+//
+//	m := callmonitor.New(client)
+//	for ev := range m.Events(ctx) {
+//	    if ev.Type == callmonitor.Ring {
+//	        fmt.Println("call from", ev.Caller)
+//	    }
+//	}
+type Monitor struct {
+	// Addr is the address of the call monitor. New derives it from the URL of the
+	// client and port 1012.
+	Addr string
+	// MinBackoff and MaxBackoff bound the delay between reconnects, which doubles
+	// after every failed attempt. They default to 1s and 1m.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Logger receives connection errors and unparsable lines. New sets it to the
+	// logger of the client; nil disables logging.
+	Logger *slog.Logger
+}
+
+// New returns a monitor for the box of c. The call monitor needs no login, so c
+// does not need to be connected.
+func New(c *fritzbox.Client) *Monitor {
+	base := c.URL()
+
+	m := &Monitor{Logger: c.Logger()}
+	if u, err := url.Parse(base); err == nil {
+		m.Addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	return m
+}
+
+// Events connects to the call monitor and delivers its events until ctx is done,
+// then closes the channel. Connection failures are logged and retried.
+func (m *Monitor) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 16)
+	go func() {
+		defer close(ch)
+		m.run(ctx, ch)
+	}()
+	return ch
+}
+
+func (m *Monitor) run(ctx context.Context, ch chan<- Event) {
+	minBackoff, maxBackoff := m.MinBackoff, m.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+	logger := m.Logger
+	if logger == nil {
+		logger = fritzbox.DiscardLogger()
+	}
+
+	backoff := minBackoff
+	for {
+		received, err := m.session(ctx, ch, logger)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = minBackoff
+		}
+		logger.Warn("call monitor disconnected", "addr", m.Addr, "error", err, "retry", backoff)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// session reads events from one connection until it fails. received reports whether
+// the connection was established, which resets the backoff.
+func (m *Monitor) session(ctx context.Context, ch chan<- Event, logger *slog.Logger) (received bool, err error) {
+	d := net.Dialer{KeepAlive: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	logger.Debug("call monitor connected", "addr", m.Addr)
+
+	// unblock the read when ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		ev, err := Parse(sc.Text())
+		if err != nil {
+			logger.Warn("skipping call monitor line", "error", err)
+			continue
+		}
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return true, err
+	}
+	return true, errors.New("connection closed by box")
+}
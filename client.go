@@ -2,29 +2,79 @@
 package fritzbox
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
+// ErrNotConnected is returned when a request is made before Connect or after Close.
+var ErrNotConnected = errors.New("not connected")
+
+// loginTimeout bounds a login, in addition to the time it may wait out a login block.
+const loginTimeout = 30 * time.Second
+
+// errSessionRejected is returned by doAha when the box does not accept the session ID.
+var errSessionRejected = errors.New("session rejected")
+
+// errForbidden is returned by doAha for a 403, which the box answers both for an invalid
+// session ID and for a user lacking the rights for the request.
+var errForbidden = errors.New("forbidden")
+
 var defaultHeaders = http.Header{
 	"Content-Type":    {"application/x-www-form-urlencoded"},
 	"Accept-Encoding": {"gzip, deflate"},
 }
 
 // Client handles authentication and communication with the FRITZ!Box.
+// A connected Client is safe for concurrent use by multiple goroutines.
 type Client struct {
 	BaseUrl  string
 	Username string
 	Password string
 
+	// mu guards the fields below and the fields of session.
+	mu             sync.RWMutex
+	session        *session
+	user           string
+	discovered     string
+	baseURL        *url.URL
+	http           *http.Client
+	checkRights    bool
+	checkFeatures  bool
+	boxInfo        *BoxInfo
+	loginBlockWait time.Duration
+	loginCall      *loginCall
+	store          SessionStore
+	credentials    CredentialProvider
+	useLastUser    bool
+	middleware     []Middleware
+	logger         *slog.Logger
+	limiter        *limiter
+	retry          *RetryPolicy
+	twoFactor      TwoFactorHandler
+	metrics        Metrics
+
+	// parent is set on copies created by WithContext, which share its state.
+	parent *Client
+	ctx    context.Context
+}
+
+// loginCall is a login in flight for session. Concurrent callers wait on done and
+// share err.
+type loginCall struct {
 	session *session
-	baseURL *url.URL
-	http    *http.Client
+	done    chan struct{}
+	err     error
 }
 
 // New creates a new client with the given credentials.
@@ -38,49 +88,160 @@ func New(username, password string) *Client {
 
 // Connect initializes and authenticates the client.
 // If already connected, the existing session is closed first.
+// If a SessionStore is set, a stored session is reused when the box still accepts it.
+// If BaseUrl is empty, the router is looked up with Discoverer.FindRouter, falling
+// back to http://192.168.178.1/.
 func (c *Client) Connect() error {
+	return c.ConnectContext(c.Context())
+}
+
+// ConnectContext is like Connect but uses ctx for the login requests.
+func (c *Client) ConnectContext(ctx context.Context) error {
+	c = c.root()
 	if c.IsConnected() {
-		c.Close()
+		_ = c.CloseContext(ctx)
 	}
 
-	if c.BaseUrl == "" {
-		c.BaseUrl = "http://192.168.178.1/"
-	} else if !strings.HasPrefix(c.BaseUrl, "http") {
-		return fmt.Errorf("base url must start with http(s)://")
+	c.discoverBase(ctx)
+	if err := c.initBase(); err != nil {
+		return err
 	}
 
+	if c.resume(ctx) {
+		c.Logger().Debug("reusing stored session")
+		return nil
+	}
+	if err := c.login(ctx, ""); err != nil {
+		return err
+	}
+	return c.saveSession()
+}
+
+// defaultBaseURL is the address boxes use in their default configuration.
+const defaultBaseURL = "http://192.168.178.1/"
+
+// URL returns the base URL of the box: BaseUrl, or if it is empty the address found
+// by Connect with Discoverer.FindRouter, or http://192.168.178.1/.
+func (c *Client) URL() string {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.urlLocked()
+}
+
+// urlLocked is URL for callers holding c.mu.
+func (c *Client) urlLocked() string {
+	switch {
+	case c.BaseUrl != "":
+		return c.BaseUrl
+	case c.discovered != "":
+		return c.discovered
+	default:
+		return defaultBaseURL
+	}
+}
+
+// initBase parses the base URL and prepares the HTTP client.
+func (c *Client) initBase() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	var err error
-	c.baseURL, err = url.Parse(c.BaseUrl)
+	c.baseURL, err = parseBaseURL(c.urlLocked())
 	if err != nil {
-		return fmt.Errorf("parse base url: %w", err)
+		return err
 	}
 
 	if c.http == nil {
 		c.http = http.DefaultClient
 	}
+	return nil
+}
+
+func parseBaseURL(base string) (*url.URL, error) {
+	if !strings.HasPrefix(base, "http") {
+		return nil, fmt.Errorf("base url must start with http(s)://")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	return u, nil
+}
 
-	return c.authenticate()
+// Close logs out of the FRITZ!Box and releases resources.
+// The local session is discarded even if the logout request fails.
+// If a SessionStore is set, it is cleared; to keep the session for the next
+// process, don't call Close.
+func (c *Client) Close() error {
+	return c.CloseContext(c.Context())
 }
 
-// Close terminates the session and releases resources.
-func (c *Client) Close() {
+// CloseContext is like Close but uses ctx for the logout request.
+func (c *Client) CloseContext(ctx context.Context) error {
+	c = c.root()
+
+	var err error
+	if cn, serr := c.snapshot(); serr == nil && cn.sid != defaultSID {
+		err = c.logout(ctx, cn.sid)
+		err = errors.Join(err, c.clearSession())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.session != nil {
 		c.session.close()
 		c.session = nil
 	}
+	// a login in flight authenticates the discarded session; later logins don't wait for it
+	c.loginCall = nil
 	if c.http != nil {
 		c.http.CloseIdleConnections()
-		c.http = nil
 	}
+	c.baseURL = nil
+	c.boxInfo = nil
+	return err
+}
+
+// logout invalidates sid on the box so it does not count against the session limit.
+func (c *Client) logout(ctx context.Context, sid string) error {
+	var resp sessionResponse
+	if err := c.loginRequest(ctx, http.MethodPost, Values{"logout": "1", "sid": sid}, &resp); err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+	if resp.SID != defaultSID {
+		return fmt.Errorf("logout: session %s still valid", resp.SID)
+	}
+	return nil
+}
+
+// LoginUser returns the name the client last logged in with. It differs from
+// Username if the name came from a CredentialProvider or SetUseLastUser, and is
+// Username before the first login.
+func (c *Client) LoginUser() string {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.user == "" {
+		return c.Username
+	}
+	return c.user
 }
 
 // IsConnected returns true if the client has an active session.
 func (c *Client) IsConnected() bool {
-	return c.http != nil
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.baseURL != nil && c.session != nil
 }
 
 // SID returns the current session ID.
 func (c *Client) SID() string {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if c.session == nil {
 		return ""
 	}
@@ -88,39 +249,305 @@ func (c *Client) SID() string {
 }
 
 func (c *Client) String() string {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if c.session == nil {
 		return "Client{not connected}"
 	}
 	return c.session.String()
 }
 
+// Context returns the client's context. Defaults to context.Background().
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext returns a copy of the client that uses ctx for all requests.
+// The copy shares the session and connection with c, so it can be passed to any
+// package function to bound it by a deadline or cancel it:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	thermostats, err := smart.GetAllThermostats(client.WithContext(ctx))
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r := c.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &Client{
+		BaseUrl:  r.BaseUrl,
+		Username: r.Username,
+		Password: r.Password,
+		parent:   r,
+		ctx:      ctx,
+	}
+}
+
+// root returns the client that holds the shared state.
+func (c *Client) root() *Client {
+	if c.parent != nil {
+		return c.parent
+	}
+	return c
+}
+
 // SetHTTPClient sets a custom HTTP client. Must be called before Connect().
 func (c *Client) SetHTTPClient(client *http.Client) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.http = client
 }
 
+// HTTPClient returns the HTTP client used for requests to the box, including the
+// TLS configuration set with SetTLSConfig. It is http.DefaultClient if none was set.
+func (c *Client) HTTPClient() *http.Client {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.http == nil {
+		return http.DefaultClient
+	}
+	return c.http
+}
+
+// Do sends a request to another interface of the box, e.g. TR-064, through the
+// middleware, rate limiter, retry policy and metrics of the client. The request is
+// sent as is: no session ID is added and the client does not need to be connected.
+// Metrics report it with API "http" and the URL path as endpoint.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	c = c.root()
+	cn, err := c.snapshot()
+	if errors.Is(err, ErrNotConnected) {
+		cn, err = c.unconnectedSnapshot()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, mc := c.startMetrics(req.Context(), "http", req.Method, req.URL.Path)
+	if mc == nil {
+		return cn.do(req)
+	}
+	return mc.finishAha(cn.do(req.WithContext(ctx)))
+}
+
 // IsExpired returns true if the session has expired due to inactivity.
+// The expiry is moved forward with every successful request.
 func (c *Client) IsExpired() bool {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.session == nil || c.session.isExpired()
 }
 
 // CheckExpiry reconnects if the session is expired or not connected.
+// Requests also re-authenticate on their own when the box rejects the session ID,
+// so calling this is only needed to reconnect ahead of time.
 func (c *Client) CheckExpiry() error {
-	if !c.IsConnected() || c.IsExpired() {
+	if !c.IsConnected() {
 		return c.Connect()
 	}
+	if c.IsExpired() {
+		return c.reauthenticate(c.Context(), c.SID())
+	}
+	return nil
+}
+
+// conn is a consistent view of the client state needed to send a request.
+type conn struct {
+	sid        string
+	base       *url.URL
+	http       *http.Client
+	middleware []Middleware
+	logger     *slog.Logger
+	limiter    *limiter
+	retry      *RetryPolicy
+}
+
+// snapshot returns the state needed to send a request.
+func (c *Client) snapshot() (conn, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.baseURL == nil || c.http == nil {
+		return conn{}, ErrNotConnected
+	}
+	return c.connLocked(c.baseURL, c.http), nil
+}
+
+// unconnectedSnapshot is like snapshot for requests that need no session, e.g. BoxInfo
+// before Connect. It resolves the base URL without storing it.
+func (c *Client) unconnectedSnapshot() (conn, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	base, err := parseBaseURL(c.urlLocked())
+	if err != nil {
+		return conn{}, err
+	}
+	hc := c.http
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return c.connLocked(base, hc), nil
+}
+
+// connLocked returns the request state for base and hc. The caller must hold c.mu.
+func (c *Client) connLocked(base *url.URL, hc *http.Client) conn {
+	cn := conn{
+		sid:        defaultSID,
+		base:       base,
+		http:       hc,
+		middleware: c.middleware,
+		logger:     c.logger,
+		limiter:    c.limiter,
+		retry:      c.retry,
+	}
+	if cn.logger == nil {
+		cn.logger = discardLogger
+	}
+	if c.session != nil {
+		cn.sid = c.session.sid
+	}
+	return cn
+}
+
+// touch extends the session expiry after a successful request.
+func (c *Client) touch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session.touch()
+}
+
+// reauthenticate logs in again after the box rejected stale.
+// Only one login runs at a time; if another caller already replaced stale,
+// this returns immediately.
+func (c *Client) reauthenticate(ctx context.Context, stale string) error {
+	err := c.login(ctx, stale)
+	c.mu.RLock()
+	m := c.metrics
+	c.mu.RUnlock()
+	if m != nil {
+		m.ObserveRelogin(err)
+	}
+	if err != nil {
+		c.Logger().Warn("re-login failed", "error", err)
+		return fmt.Errorf("re-authenticate: %w", err)
+	}
+	c.Logger().Info("session rejected, logged in again")
+
+	// the new session is already in use, so a failed save is not worth failing the request
+	if err := c.saveSession(); err != nil {
+		c.Logger().Warn("save session", "error", err)
+	}
 	return nil
 }
 
-func (c *Client) authenticate() error {
+// login performs the challenge-response login. If stale is not empty, the login is
+// skipped when the session ID has already changed. Concurrent callers wait for the
+// login in flight and share its result.
+//
+// The login runs detached from ctx, bounded by loginTimeout, so a caller giving up
+// does not fail the login for the others; each caller only stops waiting.
+func (c *Client) login(ctx context.Context, stale string) error {
+	c.mu.Lock()
+	if stale != "" && c.session != nil && c.session.sid != stale {
+		c.mu.Unlock()
+		return nil
+	}
 	if c.session == nil {
 		c.session = newSession(c)
 	}
+	call := c.loginCall
+	if call == nil || call.session != c.session {
+		call = &loginCall{session: c.session, done: make(chan struct{})}
+		c.loginCall = call
+		go c.runLogin(ctx, call, loginTimeout+c.loginBlockWait)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runLogin authenticates the session of call and wakes up its waiters.
+func (c *Client) runLogin(ctx context.Context, call *loginCall, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	call.err = c.authenticate(ctx, call.session)
+
+	c.mu.Lock()
+	if c.loginCall == call {
+		c.loginCall = nil
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// authenticate logs in with s. While the box blocks logins, it either returns
+// a *LoginBlockedError or, if the block is within loginBlockWait, waits it out.
+func (c *Client) authenticate(ctx context.Context, s *session) error {
+	for {
+		blockTime, err := s.open(ctx)
+		if err != nil {
+			return err
+		}
+		if blockTime == 0 {
+			break
+		}
+
+		c.mu.RLock()
+		maxWait := c.loginBlockWait
+		c.mu.RUnlock()
+		if blockTime > maxWait {
+			return &LoginBlockedError{Wait: blockTime}
+		}
+		c.Logger().Warn("login blocked, waiting", "wait", blockTime)
+
+		t := time.NewTimer(blockTime)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	return s.auth(ctx)
+}
+
+// SetLoginBlockWait makes logins wait out a block of up to max instead of
+// returning ErrLoginBlocked. The box blocks logins for growing intervals after
+// failed attempts. Zero (the default) disables waiting.
+func (c *Client) SetLoginBlockWait(max time.Duration) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loginBlockWait = max
+}
+
+// loginRequest sends a request to login_sid.lua, bypassing rights checks and re-authentication.
+func (c *Client) loginRequest(ctx context.Context, method string, data Values, target any) error {
+	cn, err := c.snapshot()
+	if err != nil {
+		return err
+	}
 
-	if err := c.session.open(); err != nil {
+	resp, err := c.doAha(ctx, cn, method, loginPath, data)
+	if err != nil {
 		return err
 	}
-	return c.session.auth()
+	defer resp.Body.Close()
+
+	return xml.NewDecoder(resp.Body).Decode(target)
 }
 
 // AHA HTTP Interface methods - used by aha package.
@@ -129,7 +556,75 @@ func (c *Client) authenticate() error {
 // AhaRequest sends a form-encoded request to the FRITZ!Box.
 // GET: data as query parameters. POST: data as form body.
 func (c *Client) AhaRequest(method, path string, data Values) (*http.Response, error) {
-	u, err := c.resolveURL(path)
+	return c.AhaRequestContext(c.Context(), method, path, data)
+}
+
+// AhaRequestContext is like AhaRequest but uses ctx for the request.
+//
+// If the box rejects the session ID, the client logs in again once and replays
+// the request. A "sid" entry in data is replaced with the new session ID.
+// A 403 for a valid session returns an error wrapping ErrInsufficientRights.
+func (c *Client) AhaRequestContext(ctx context.Context, method, path string, data Values) (*http.Response, error) {
+	c = c.root()
+	ctx, mc := c.startMetrics(ctx, "aha", method, ahaEndpoint(path, data))
+	if mc == nil {
+		return c.ahaRequest(ctx, method, path, data)
+	}
+	return mc.finishAha(c.ahaRequest(ctx, method, path, data))
+}
+
+func (c *Client) ahaRequest(ctx context.Context, method, path string, data Values) (*http.Response, error) {
+	if err := c.preflight(ctx, path, isAhaWrite(data)); err != nil {
+		return nil, err
+	}
+	ctx = withWrite(ctx, isAhaWrite(data))
+
+	cn, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doAha(ctx, cn, method, path, data)
+	if errors.Is(err, errForbidden) && cn.sid != defaultSID {
+		err = c.checkForbidden(ctx, cn.sid)
+	}
+	if errors.Is(err, errSessionRejected) && cn.sid != defaultSID {
+		if err := c.reauthenticate(ctx, cn.sid); err != nil {
+			return nil, err
+		}
+		cn, err = c.snapshot()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := data["sid"]; ok {
+			data = data.with("sid", cn.sid)
+		}
+		resp, err = c.doAha(ctx, cn, method, path, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.touch()
+	return resp, nil
+}
+
+// checkForbidden asks the box whether sid is still valid after a 403. It returns
+// errSessionRejected if not, so the caller logs in again, and an error wrapping
+// ErrInsufficientRights otherwise.
+func (c *Client) checkForbidden(ctx context.Context, sid string) error {
+	var resp sessionResponse
+	if err := c.loginRequest(ctx, http.MethodGet, Values{"sid": sid}, &resp); err != nil {
+		return fmt.Errorf("check session: %w", err)
+	}
+	if resp.SID != sid {
+		return errSessionRejected
+	}
+	return fmt.Errorf("http %d: %w", http.StatusForbidden, ErrInsufficientRights)
+}
+
+func (c *Client) doAha(ctx context.Context, cn conn, method, path string, data Values) (*http.Response, error) {
+	u, err := resolveURL(cn.base, path)
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +634,7 @@ func (c *Client) AhaRequest(method, path string, data Values) (*http.Response, e
 		body = strings.NewReader(data.Encode())
 	}
 
-	req, err := http.NewRequest(method, u.String(), body)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -153,22 +648,44 @@ func (c *Client) AhaRequest(method, path string, data Values) (*http.Response, e
 		req.URL.RawQuery = q.Encode()
 	}
 
-	resp, err := c.http.Do(req)
+	resp, err := cn.do(req)
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, fmt.Errorf("http %d: %w", resp.StatusCode, errForbidden)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		resp.Body.Close()
 		return nil, fmt.Errorf("http %d", resp.StatusCode)
 	}
 
+	// data.lua answers with 200 and an empty session when the SID is invalid
+	if strings.HasSuffix(u.Path, "/data.lua") {
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+		if bytes.Contains(b, []byte(`"sid":"`+defaultSID+`"`)) {
+			return nil, errSessionRejected
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
 	return resp, nil
 }
 
 // AhaRequestString sends a request and returns the response body as a string.
 func (c *Client) AhaRequestString(method, path string, data Values) (int, string, error) {
-	resp, err := c.AhaRequest(method, path, data)
+	return c.AhaRequestStringContext(c.Context(), method, path, data)
+}
+
+// AhaRequestStringContext is like AhaRequestString but uses ctx for the request.
+func (c *Client) AhaRequestStringContext(ctx context.Context, method, path string, data Values) (int, string, error) {
+	resp, err := c.AhaRequestContext(ctx, method, path, data)
 	if err != nil {
 		return 0, "", err
 	}
@@ -184,7 +701,12 @@ func (c *Client) AhaRequestString(method, path string, data Values) (int, string
 
 // AhaRequestXML sends a request and decodes the XML response into target.
 func (c *Client) AhaRequestXML(method, path string, data Values, target any) error {
-	resp, err := c.AhaRequest(method, path, data)
+	return c.AhaRequestXMLContext(c.Context(), method, path, data, target)
+}
+
+// AhaRequestXMLContext is like AhaRequestXML but uses ctx for the request.
+func (c *Client) AhaRequestXMLContext(ctx context.Context, method, path string, data Values, target any) error {
+	resp, err := c.AhaRequestContext(ctx, method, path, data)
 	if err != nil {
 		return err
 	}
@@ -193,12 +715,12 @@ func (c *Client) AhaRequestXML(method, path string, data Values, target any) err
 	return xml.NewDecoder(resp.Body).Decode(target)
 }
 
-func (c *Client) resolveURL(path string) (*url.URL, error) {
+func resolveURL(base *url.URL, path string) (*url.URL, error) {
 	rel, err := url.Parse(path)
 	if err != nil {
 		return nil, fmt.Errorf("parse path: %w", err)
 	}
-	return c.baseURL.ResolveReference(rel), nil
+	return base.ResolveReference(rel), nil
 }
 
 // REST API methods - used by smarthome and unsafe packages.
@@ -207,7 +729,80 @@ func (c *Client) resolveURL(path string) (*url.URL, error) {
 // RestRequest sends a JSON request to the FRITZ!Box REST API.
 // Body is JSON-marshaled for PUT/POST requests. Pass nil for GET/DELETE.
 func (c *Client) RestRequest(method, path string, body any) ([]byte, int, error) {
-	u, err := c.resolveURL(path)
+	return c.RestRequestContext(c.Context(), method, path, body)
+}
+
+// RestRequestContext is like RestRequest but uses ctx for the request.
+//
+// If the box rejects the session ID with 401, or with 403 and no error list, the client
+// logs in again once and replays the request.
+// If it requires a second factor and a TwoFactorHandler is set, the request is confirmed
+// and replayed; see SetTwoFactorHandler.
+func (c *Client) RestRequestContext(ctx context.Context, method, path string, body any) ([]byte, int, error) {
+	c = c.root()
+	ctx, mc := c.startMetrics(ctx, "rest", method, restEndpoint(path))
+	respBody, status, err := c.restRequest(ctx, method, path, body)
+	if mc != nil {
+		mc.finish(respBody, err)
+	}
+	return respBody, status, err
+}
+
+func (c *Client) restRequest(ctx context.Context, method, path string, body any) ([]byte, int, error) {
+	if err := c.preflight(ctx, path, isRestWrite(method)); err != nil {
+		return nil, 0, err
+	}
+	ctx = withWrite(ctx, isRestWrite(method))
+
+	cn, err := c.snapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	respBody, status, err := c.doRest(ctx, cn, method, path, body)
+	if err == nil && cn.sid != defaultSID && restSessionRejected(status, respBody) {
+		if err := c.reauthenticate(ctx, cn.sid); err != nil {
+			return nil, 0, err
+		}
+		cn, err = c.snapshot()
+		if err != nil {
+			return nil, 0, err
+		}
+		respBody, status, err = c.doRest(ctx, cn, method, path, body)
+	}
+	if err == nil && status >= 400 && errors.Is(TwoFactorError(respBody), ErrTwoFactorRequired) {
+		c.mu.RLock()
+		h := c.twoFactor
+		c.mu.RUnlock()
+		if h != nil {
+			c.Logger().Info("confirming request with second factor", "path", path)
+			if err := c.ConfirmTwoFactor(ctx, h); err != nil {
+				return nil, 0, err
+			}
+			// the confirmation belongs to the session, which may have been renewed meanwhile
+			cn, err = c.snapshot()
+			if err != nil {
+				return nil, 0, err
+			}
+			respBody, status, err = c.doRest(ctx, cn, method, path, body)
+		}
+	}
+	if err == nil && status >= 200 && status <= 299 {
+		c.touch()
+	}
+	return respBody, status, err
+}
+
+// restSessionRejected reports whether a REST answer rejects the session ID. A 403 with
+// an error list, e.g. 3001 for missing rights or 3008 for a missing second factor,
+// concerns the request and leaves the session valid.
+func restSessionRejected(status int, body []byte) bool {
+	return status == http.StatusUnauthorized ||
+		(status == http.StatusForbidden && len(restErrorCodes(body)) == 0)
+}
+
+func (c *Client) doRest(ctx context.Context, cn conn, method, path string, body any) ([]byte, int, error) {
+	u, err := resolveURL(cn.base, path)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -221,18 +816,18 @@ func (c *Client) RestRequest(method, path string, body any) ([]byte, int, error)
 		bodyReader = strings.NewReader(string(jsonBytes))
 	}
 
-	req, err := http.NewRequest(method, u.String(), bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
 	if err != nil {
 		return nil, 0, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "AVM-SID "+c.SID())
+	req.Header.Set("Authorization", "AVM-SID "+cn.sid)
 	req.Header.Set("Accept", "application/json")
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := c.http.Do(req)
+	resp, err := cn.do(req)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,189 @@
+package fritzbox
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config describes how to reach and log in to a FRITZ!Box.
+// It can be loaded from a JSON file with LoadConfig.
+//
+// Credentials are taken from the first available source: the Credentials provider,
+// Password, PasswordFile, Netrc, and finally the FRITZBOX_USERNAME and
+// FRITZBOX_PASSWORD environment variables.
+type Config struct {
+	BaseUrl      string `json:"base_url"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+	Netrc        string `json:"netrc"`
+
+	// UseLastUser logs in as the last used user if no username is configured.
+	// See Client.SetUseLastUser.
+	UseLastUser bool `json:"use_last_user"`
+
+	// Timeout limits each HTTP request. JSON accepts "10s" or a number of seconds.
+	Timeout Duration `json:"timeout"`
+
+	TLS TLSConfig `json:"tls"`
+
+	// Credentials overrides all other credential sources.
+	Credentials CredentialProvider `json:"-"`
+}
+
+// TLSConfig configures HTTPS connections to the box.
+type TLSConfig struct {
+	// CAFile is a PEM file with certificates to trust in addition to the system pool.
+	CAFile string `json:"ca_file"`
+	// InsecureSkipVerify disables certificate verification.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// Fingerprint pins the SHA-256 fingerprint of the box certificate instead of
+	// verifying its chain. See PinnedTLSConfig.
+	Fingerprint string `json:"fingerprint"`
+	// FingerprintFile enables trust on first use: the fingerprint seen first is
+	// pinned in this file. See TOFUTLSConfig. Ignored if Fingerprint is set.
+	FingerprintFile string `json:"fingerprint_file"`
+}
+
+// Duration is a time.Duration that unmarshals from "10s" or a number of seconds.
+// Like other scalars, null leaves it unchanged, so an unset duration stays zero.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		secs, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s", string(b))
+		}
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads a JSON config file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// NewFromConfig creates a client from cfg. Call Connect() to authenticate with the FRITZ!Box.
+func NewFromConfig(cfg Config) (*Client, error) {
+	c := &Client{
+		BaseUrl:     cfg.BaseUrl,
+		Username:    cfg.Username,
+		useLastUser: cfg.UseLastUser,
+	}
+
+	switch {
+	case cfg.Credentials != nil:
+		c.credentials = cfg.Credentials
+	case cfg.Password != "":
+		c.Password = cfg.Password
+	case cfg.PasswordFile != "":
+		c.credentials = FileCredentials{Username: cfg.Username, PasswordFile: cfg.PasswordFile}
+	case cfg.Netrc != "":
+		c.credentials = NetrcCredentials{Path: cfg.Netrc, Machine: hostOf(cfg.BaseUrl)}
+	default:
+		c.credentials = envCredentialsWithUser{username: cfg.Username}
+	}
+
+	if cfg.Timeout != 0 || cfg.TLS != (TLSConfig{}) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		tlsConfig, err := cfg.TLS.build(hostOf(cfg.BaseUrl))
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+		c.http = &http.Client{Transport: transport, Timeout: time.Duration(cfg.Timeout)}
+	}
+
+	return c, nil
+}
+
+// build creates the tls.Config for t. Returns nil if t is empty.
+// host is the key for fingerprints pinned on first use.
+func (t TLSConfig) build(host string) (*tls.Config, error) {
+	if t == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	switch {
+	case t.Fingerprint != "":
+		return PinnedTLSConfig(t.Fingerprint), nil
+	case t.FingerprintFile != "":
+		return TOFUTLSConfig(NewFileFingerprintStore(t.FingerprintFile), host), nil
+	}
+
+	tc := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ca file contains no certificates")
+		}
+		tc.RootCAs = pool
+	}
+	return tc, nil
+}
+
+// envCredentialsWithUser reads the environment but falls back to a configured username.
+type envCredentialsWithUser struct {
+	username string
+}
+
+func (e envCredentialsWithUser) Credentials(ctx context.Context) (string, string, error) {
+	username, password, err := EnvCredentials{}.Credentials(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if username == "" {
+		username = e.username
+	}
+	return username, password, nil
+}
+
+// hostOf returns the host name of a base URL, or "fritz.box" if there is none.
+func hostOf(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return "fritz.box"
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,207 @@
+package fritzbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoCredentials is returned when a CredentialProvider finds no credentials.
+var ErrNoCredentials = errors.New("no credentials")
+
+// CredentialProvider supplies the login credentials.
+// It is asked on every login, so rotated secrets are picked up on the next re-login.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (username, password string, err error)
+}
+
+// StaticCredentials is a CredentialProvider with fixed credentials.
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+func (s StaticCredentials) Credentials(context.Context) (string, string, error) {
+	return s.Username, s.Password, nil
+}
+
+// EnvCredentials reads the credentials from environment variables.
+// UsernameVar and PasswordVar default to FRITZBOX_USERNAME and FRITZBOX_PASSWORD.
+// The username may be empty.
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+func (e EnvCredentials) Credentials(context.Context) (string, string, error) {
+	userVar, passVar := e.UsernameVar, e.PasswordVar
+	if userVar == "" {
+		userVar = "FRITZBOX_USERNAME"
+	}
+	if passVar == "" {
+		passVar = "FRITZBOX_PASSWORD"
+	}
+
+	password, ok := os.LookupEnv(passVar)
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s not set", ErrNoCredentials, passVar)
+	}
+	return os.Getenv(userVar), password, nil
+}
+
+// FileCredentials reads the credentials from files, e.g. Docker secrets in /run/secrets.
+// Surrounding whitespace is trimmed. If UsernameFile is empty, Username is used.
+type FileCredentials struct {
+	Username     string
+	UsernameFile string
+	PasswordFile string
+}
+
+func (f FileCredentials) Credentials(context.Context) (string, string, error) {
+	username := f.Username
+	if f.UsernameFile != "" {
+		b, err := os.ReadFile(f.UsernameFile)
+		if err != nil {
+			return "", "", fmt.Errorf("read username file: %w", err)
+		}
+		username = strings.TrimSpace(string(b))
+	}
+
+	b, err := os.ReadFile(f.PasswordFile)
+	if err != nil {
+		return "", "", fmt.Errorf("read password file: %w", err)
+	}
+	return username, strings.TrimSpace(string(b)), nil
+}
+
+// NetrcCredentials reads the credentials from a netrc file.
+// Path defaults to ~/.netrc, Machine to "fritz.box". A "default" entry is used
+// if no entry matches Machine.
+type NetrcCredentials struct {
+	Path    string
+	Machine string
+}
+
+func (n NetrcCredentials) Credentials(context.Context) (string, string, error) {
+	path := n.Path
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("find netrc: %w", err)
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	machine := n.Machine
+	if machine == "" {
+		machine = "fritz.box"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("open netrc: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	var inMacro bool
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		// a macro runs from the line after macdef to the next empty line
+		if inMacro {
+			inMacro = line != ""
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				fields, inMacro = fields[:i], true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := sc.Err(); err != nil {
+		return "", "", fmt.Errorf("read netrc: %w", err)
+	}
+
+	entries := parseNetrc(tokens)
+	if e, ok := entries[machine]; ok {
+		return e[0], e[1], nil
+	}
+	if e, ok := entries[""]; ok {
+		return e[0], e[1], nil
+	}
+	return "", "", fmt.Errorf("%w: no netrc entry for %s", ErrNoCredentials, machine)
+}
+
+// parseNetrc returns login and password per machine; the default entry has an empty key.
+func parseNetrc(tokens []string) map[string][2]string {
+	entries := make(map[string][2]string)
+	var machine string
+	var inEntry bool
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			if i+1 < len(tokens) {
+				i++
+				machine, inEntry = tokens[i], true
+			}
+		case "default":
+			machine, inEntry = "", true
+		case "login", "password", "account":
+			if i+1 >= len(tokens) || !inEntry {
+				continue
+			}
+			i++
+			e := entries[machine]
+			switch tokens[i-1] {
+			case "login":
+				e[0] = tokens[i]
+			case "password":
+				e[1] = tokens[i]
+			}
+			entries[machine] = e
+		}
+	}
+	return entries
+}
+
+// SetCredentialProvider sets the provider asked for credentials on every login.
+// It takes precedence over the Username and Password fields.
+func (c *Client) SetCredentialProvider(p CredentialProvider) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials = p
+}
+
+// Credentials returns the credentials the client logs in with, for protocols that
+// authenticate on their own, such as TR-064.
+func (c *Client) Credentials(ctx context.Context) (username, password string, err error) {
+	return c.root().loginCredentials(ctx)
+}
+
+// loginCredentials returns the credentials to log in with.
+func (c *Client) loginCredentials(ctx context.Context) (username, password string, err error) {
+	c.mu.RLock()
+	p := c.credentials
+	username, password = c.Username, c.Password
+	c.mu.RUnlock()
+
+	if p == nil {
+		return username, password, nil
+	}
+	username, password, err = p.Credentials(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("get credentials: %w", err)
+	}
+	return username, password, nil
+}
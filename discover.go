@@ -0,0 +1,302 @@
+package fritzbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNoBoxFound is returned by Discover when no box answered.
+var ErrNoBoxFound = errors.New("no FRITZ!Box found")
+
+// tr064DeviceType is searched for via SSDP. Only AVM boxes and repeaters offer it.
+const tr064DeviceType = "urn:dslforum-org:device:InternetGatewayDevice:1"
+
+// wanDeviceType is only listed by boxes that route, not by repeaters.
+const wanDeviceType = "urn:dslforum-org:device:WANDevice:1"
+
+// DiscoveredBox is a box found on the local network.
+type DiscoveredBox struct {
+	// BaseURL is the web interface, usable as Client.BaseUrl.
+	BaseURL string
+	// Model is the product name, e.g. "FRITZ!Box 7590".
+	Model        string
+	FriendlyName string
+	// Firmware is the full firmware version, e.g. "154.07.57".
+	Firmware string
+	Version  Version
+	// UDN is the unique device name from the UPnP description.
+	UDN string
+	// Location is the URL of the TR-064 description.
+	Location string
+	// Router is set if the box has a WAN interface, i.e. it is not a repeater or a
+	// box in repeater mode.
+	Router bool
+}
+
+// Discoverer finds boxes on the local network. The zero value is ready to use.
+type Discoverer struct {
+	// Addr is the SSDP multicast address. Defaults to 239.255.255.250:1900.
+	Addr string
+	// Wait is how long to collect answers. Defaults to 2s; a shorter deadline of
+	// the context takes precedence.
+	Wait time.Duration
+	// FallbackHost is resolved via DNS if no box answers the search, and before the
+	// search by FindRouter. Defaults to "fritz.box"; set to "-" to disable it.
+	FallbackHost string
+	// HTTPClient fetches the device descriptions. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Discover finds boxes on the local network with the default Discoverer.
+// It sends an SSDP search for the TR-064 device and, if no box answers, resolves
+// fritz.box, which the box serves to its DHCP clients.
+func Discover(ctx context.Context) ([]DiscoveredBox, error) {
+	var d Discoverer
+	return d.Discover(ctx)
+}
+
+// Discover finds boxes on the local network, in the order they answered.
+func (d *Discoverer) Discover(ctx context.Context) ([]DiscoveredBox, error) {
+	boxes, err := d.ssdp(ctx)
+	if len(boxes) > 0 || ctx.Err() != nil {
+		return boxes, err
+	}
+
+	box, ferr := d.fallback(ctx)
+	if ferr != nil {
+		return nil, errors.Join(ErrNoBoxFound, err, ferr)
+	}
+	return []DiscoveredBox{box}, nil
+}
+
+// FindRouter finds the box that routes the local network, skipping repeaters.
+// It first resolves the fallback host, which the router serves to its DHCP clients,
+// and only then searches via SSDP for a box with a WAN interface, or any box if
+// none has one.
+func (d *Discoverer) FindRouter(ctx context.Context) (DiscoveredBox, error) {
+	box, err := d.fallback(ctx)
+	if err == nil || ctx.Err() != nil {
+		return box, err
+	}
+
+	boxes, serr := d.ssdp(ctx)
+	if len(boxes) == 0 {
+		return DiscoveredBox{}, errors.Join(ErrNoBoxFound, err, serr)
+	}
+	for _, b := range boxes {
+		if b.Router {
+			return b, nil
+		}
+	}
+	return boxes[0], nil
+}
+
+// ssdp collects the boxes answering the search until the wait time is over.
+func (d *Discoverer) ssdp(ctx context.Context) ([]DiscoveredBox, error) {
+	locations, err := d.search(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+
+	var boxes []DiscoveredBox
+	seen := make(map[string]bool)
+	for _, loc := range locations {
+		box, err := d.describe(ctx, loc)
+		if err != nil || seen[box.UDN] {
+			continue
+		}
+		seen[box.UDN] = true
+		boxes = append(boxes, box)
+	}
+	return boxes, err
+}
+
+// search sends an SSDP M-SEARCH and returns the description URLs of the answers.
+func (d *Discoverer) search(ctx context.Context) ([]string, error) {
+	addr := d.Addr
+	if addr == "" {
+		addr = "239.255.255.250:1900"
+	}
+	wait := d.Wait
+	if wait <= 0 {
+		wait = 2 * time.Second
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + addr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: " + tr064DeviceType + "\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(msg), raddr); err != nil {
+		return nil, fmt.Errorf("send ssdp search: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// the read deadline ends the search
+			return locations, ctx.Err()
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		loc := resp.Header.Get("Location")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("St") != tr064DeviceType || loc == "" || seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		locations = append(locations, loc)
+	}
+}
+
+// tr64Desc is the part of tr64desc.xml needed to identify a box.
+type tr64Desc struct {
+	SystemVersion struct {
+		Display string `xml:"Display"`
+	} `xml:"systemVersion"`
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		ModelName    string `xml:"modelName"`
+		UDN          string `xml:"UDN"`
+		DeviceList   []struct {
+			DeviceType string `xml:"deviceType"`
+		} `xml:"deviceList>device"`
+	} `xml:"device"`
+}
+
+// describe fetches the TR-064 description at location.
+func (d *Discoverer) describe(ctx context.Context, location string) (DiscoveredBox, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return DiscoveredBox{}, err
+	}
+	client := d.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return DiscoveredBox{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return DiscoveredBox{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return DiscoveredBox{}, fmt.Errorf("get %s: http %d", location, resp.StatusCode)
+	}
+
+	var desc tr64Desc
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return DiscoveredBox{}, fmt.Errorf("parse %s: %w", location, err)
+	}
+
+	box := DiscoveredBox{
+		// the description is served on the TR-064 port, the web interface on the default one
+		BaseURL:      "http://" + hostForURL(u.Hostname()) + "/",
+		Model:        desc.Device.ModelName,
+		FriendlyName: desc.Device.FriendlyName,
+		Firmware:     desc.SystemVersion.Display,
+		UDN:          desc.Device.UDN,
+		Location:     location,
+	}
+	if v, err := ParseVersion(box.Firmware); err == nil {
+		box.Version = v
+	}
+	if box.UDN == "" {
+		box.UDN = location
+	}
+	for _, dev := range desc.Device.DeviceList {
+		if dev.DeviceType == wanDeviceType {
+			box.Router = true
+		}
+	}
+	return box, nil
+}
+
+// fallback resolves FallbackHost and describes the box found there.
+func (d *Discoverer) fallback(ctx context.Context) (DiscoveredBox, error) {
+	host := d.FallbackHost
+	if host == "-" {
+		return DiscoveredBox{}, errors.New("fallback host disabled")
+	}
+	if host == "" {
+		host = "fritz.box"
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return DiscoveredBox{}, err
+	}
+	location := "http://" + net.JoinHostPort(addrs[0], "49000") + "/tr64desc.xml"
+	if box, err := d.describe(ctx, location); err == nil {
+		return box, nil
+	}
+	// TR-064 may be disabled; the web interface is still there
+	return DiscoveredBox{BaseURL: "http://" + hostForURL(addrs[0]) + "/", Router: true}, nil
+}
+
+// hostForURL brackets IPv6 addresses.
+func hostForURL(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// discoverBase looks up the router with FindRouter if neither BaseUrl nor an earlier
+// discovery gives the address. If no box is found, URL returns the default address.
+func (c *Client) discoverBase(ctx context.Context) {
+	c.mu.RLock()
+	unset := c.BaseUrl == "" && c.discovered == ""
+	c.mu.RUnlock()
+	if !unset {
+		return
+	}
+
+	var d Discoverer
+	box, err := d.FindRouter(ctx)
+	if err != nil {
+		c.Logger().Debug("discovery failed, using default address", "error", err)
+		return
+	}
+	c.Logger().Debug("discovered box", "model", box.Model, "url", box.BaseURL)
+
+	c.mu.Lock()
+	c.discovered = box.BaseURL
+	c.mu.Unlock()
+}
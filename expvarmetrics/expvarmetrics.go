@@ -0,0 +1,187 @@
+// Package expvarmetrics publishes the request metrics of a fritzbox.Client via expvar.
+// It is a separate package because importing expvar registers /debug/vars on the
+// default HTTP mux.
+package expvarmetrics
+
+import (
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram buckets.
+var latencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Metrics implements fritzbox.Metrics by publishing to expvar, and thus on
+// /debug/vars of the default HTTP mux. The published map holds:
+//
+//	requests          requests by "METHOD endpoint"
+//	latency           a histogram by "METHOD endpoint", in milliseconds
+//	errors            failed requests by "METHOD endpoint"
+//	status            non-2xx answers by status code
+//	rest_codes        REST error lists by their first code
+//	relogins          logins after a rejected session
+//	relogin_failures  those of them that failed
+//	bytes_sent        request bodies
+//	bytes_received    response bodies
+type Metrics struct {
+	vars *expvar.Map
+
+	requests        *expvar.Map
+	latency         *expvar.Map
+	errors          *expvar.Map
+	status          *expvar.Map
+	restCodes       *expvar.Map
+	relogins        *expvar.Int
+	reloginFailures *expvar.Int
+	bytesSent       *expvar.Int
+	bytesReceived   *expvar.Int
+
+	// mu guards the creation of histograms.
+	mu sync.Mutex
+}
+
+// New publishes the metrics under name. Like expvar.Publish, it panics if name is
+// already in use; clients sharing the metrics share one instance:
+//
+//	m := expvarmetrics.New("fritzbox")
+//	client.SetMetrics(m)
+func New(name string) *Metrics {
+	m := NewUnpublished()
+	expvar.Publish(name, m.vars)
+	return m
+}
+
+// NewUnpublished returns metrics that are not published. Publish Vars under a name
+// of your choice, or read it directly, e.g. in tests.
+func NewUnpublished() *Metrics {
+	m := &Metrics{
+		vars:            new(expvar.Map),
+		requests:        new(expvar.Map),
+		latency:         new(expvar.Map),
+		errors:          new(expvar.Map),
+		status:          new(expvar.Map),
+		restCodes:       new(expvar.Map),
+		relogins:        new(expvar.Int),
+		reloginFailures: new(expvar.Int),
+		bytesSent:       new(expvar.Int),
+		bytesReceived:   new(expvar.Int),
+	}
+	m.vars.Set("requests", m.requests)
+	m.vars.Set("latency", m.latency)
+	m.vars.Set("errors", m.errors)
+	m.vars.Set("status", m.status)
+	m.vars.Set("rest_codes", m.restCodes)
+	m.vars.Set("relogins", m.relogins)
+	m.vars.Set("relogin_failures", m.reloginFailures)
+	m.vars.Set("bytes_sent", m.bytesSent)
+	m.vars.Set("bytes_received", m.bytesReceived)
+	return m
+}
+
+// Vars returns the map holding the metrics.
+func (m *Metrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+// ObserveRequest implements fritzbox.Metrics.
+func (m *Metrics) ObserveRequest(s fritzbox.RequestStats) {
+	key := s.Method + " " + s.Endpoint
+	m.requests.Add(key, 1)
+	m.histogram(key).observe(s.Duration)
+	if s.Err != nil {
+		m.errors.Add(key, 1)
+	}
+	if s.StatusCode != 0 && (s.StatusCode < 200 || s.StatusCode > 299) {
+		m.status.Add(strconv.Itoa(s.StatusCode), 1)
+	}
+	if s.RestCode != 0 {
+		m.restCodes.Add(strconv.Itoa(s.RestCode), 1)
+	}
+	m.bytesSent.Add(s.BytesSent)
+	m.bytesReceived.Add(s.BytesReceived)
+}
+
+// ObserveRelogin implements fritzbox.Metrics.
+func (m *Metrics) ObserveRelogin(err error) {
+	m.relogins.Add(1)
+	if err != nil {
+		m.reloginFailures.Add(1)
+	}
+}
+
+// histogram returns the latency histogram of key, creating it if needed.
+func (m *Metrics) histogram(key string) *histogram {
+	if h, ok := m.latency.Get(key).(*histogram); ok {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.latency.Get(key).(*histogram); ok {
+		return h
+	}
+	h := &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+	m.latency.Set(key, h)
+	return h
+}
+
+// histogram is a cumulative latency histogram published as JSON:
+//
+//	{"count": 3, "sum_ms": 42.5, "buckets": {"10": 1, "25": 2, ..., "+Inf": 3}}
+type histogram struct {
+	mu     sync.Mutex
+	counts []int64
+	count  int64
+	sum    time.Duration
+}
+
+func (h *histogram) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+// String implements expvar.Var.
+func (h *histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `{"count": %d, "sum_ms": %g, "buckets": {`, h.count, float64(h.sum)/float64(time.Millisecond))
+	var cumulative int64
+	for i, n := range h.counts {
+		cumulative += n
+		bound := "+Inf"
+		if i < len(latencyBuckets) {
+			bound = strconv.FormatInt(latencyBuckets[i].Milliseconds(), 10)
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%q: %d", bound, cumulative)
+	}
+	sb.WriteString("}}")
+	return sb.String()
+}
@@ -0,0 +1,310 @@
+package fritztest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+// AHA function bitmask bits for the capabilities the fake box emulates.
+const (
+	bitHkr         = 1 << 6
+	bitTempSensor  = 1 << 8
+	ahaTempOff     = 253
+	ahaTempMax     = 254
+	ahaFwVersion   = "8.20"
+	ahaListVersion = "1"
+)
+
+type ahaDeviceList struct {
+	XMLName   xml.Name    `xml:"devicelist"`
+	Version   string      `xml:"version,attr"`
+	FwVersion string      `xml:"fwversion,attr"`
+	Devices   []ahaDevice `xml:"device"`
+}
+
+type ahaDevice struct {
+	XMLName         xml.Name        `xml:"device"`
+	Identifier      string          `xml:"identifier,attr"`
+	ID              int             `xml:"id,attr"`
+	FunctionBitmask int             `xml:"functionbitmask,attr"`
+	FwVersion       string          `xml:"fwversion,attr"`
+	Manufacturer    string          `xml:"manufacturer,attr"`
+	ProductName     string          `xml:"productname,attr"`
+	Present         int             `xml:"present"`
+	TxBusy          int             `xml:"txbusy"`
+	Name            string          `xml:"name"`
+	Battery         *int            `xml:"battery,omitempty"`
+	BatteryLow      *int            `xml:"batterylow,omitempty"`
+	Temperature     *ahaTemperature `xml:"temperature,omitempty"`
+	Hkr             *ahaHkr         `xml:"hkr,omitempty"`
+}
+
+type ahaTemperature struct {
+	Celsius int `xml:"celsius"`
+	Offset  int `xml:"offset"`
+}
+
+type ahaHkr struct {
+	Tsoll                   int   `xml:"tsoll"`
+	Absenk                  int   `xml:"absenk"`
+	Komfort                 int   `xml:"komfort"`
+	Lock                    int   `xml:"lock"`
+	Devicelock              int   `xml:"devicelock"`
+	Errorcode               int   `xml:"errorcode"`
+	Windowopenactiv         int   `xml:"windowopenactiv"`
+	Windowopenactiveendtime int64 `xml:"windowopenactiveendtime"`
+	Boostactive             int   `xml:"boostactive"`
+	Boostactiveendtime      int64 `xml:"boostactiveendtime"`
+	Batterylow              int   `xml:"batterylow"`
+	Battery                 int   `xml:"battery"`
+	Summeractive            int   `xml:"summeractive"`
+	Holidayactive           int   `xml:"holidayactive"`
+}
+
+// handleAha serves the homeautoswitch.lua commands used by the aha package.
+func (s *Server) handleAha(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &s.model
+	cmd := r.Form.Get("switchcmd")
+	access := fritzbox.AccessWrite
+	if strings.HasPrefix(cmd, "get") {
+		access = fritzbox.AccessRead
+	}
+	if sid := r.Form.Get("sid"); !s.validSID(sid) || !s.allowed(sid, fritzbox.RightHomeAuto, access) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if cmd == "getdevicelistinfos" {
+		dl := ahaDeviceList{Version: ahaListVersion, FwVersion: ahaFwVersion}
+		for i := range m.Devices {
+			dl.Devices = append(dl.Devices, s.readTxBusy(m.ahaDevice(i)))
+		}
+		writeXML(w, dl)
+		return
+	}
+
+	i := m.deviceIndex(r.Form.Get("ain"))
+	if i < 0 {
+		http.Error(w, "inval", http.StatusBadRequest)
+		return
+	}
+	dev := m.ahaDevice(i)
+	thermostat := m.thermostatUnit(&m.Devices[i])
+
+	switch cmd {
+	case "getdeviceinfos":
+		writeXML(w, s.readTxBusy(dev))
+	case "getswitchname":
+		writeText(w, dev.Name)
+	case "getswitchpresent":
+		writeText(w, strconv.Itoa(dev.Present))
+	case "setname":
+		name := r.Form.Get("name")
+		m.Devices[i].Name = name
+		for _, u := range m.deviceUnits(&m.Devices[i]) {
+			_ = m.patchUnit(u.UID, mustJSON(map[string]any{"name": name}))
+		}
+		writeText(w, name)
+	case "gettemperature":
+		if dev.Temperature == nil {
+			http.Error(w, "inval", http.StatusBadRequest)
+			return
+		}
+		writeText(w, strconv.Itoa(dev.Temperature.Celsius))
+	case "gethkrtsoll", "gethkrkomfort", "gethkrabsenk":
+		if dev.Hkr == nil {
+			http.Error(w, "inval", http.StatusBadRequest)
+			return
+		}
+		v := map[string]int{"gethkrtsoll": dev.Hkr.Tsoll, "gethkrkomfort": dev.Hkr.Komfort, "gethkrabsenk": dev.Hkr.Absenk}[cmd]
+		writeText(w, strconv.Itoa(v))
+	case "sethkrtsoll":
+		param, err := strconv.Atoi(r.Form.Get("param"))
+		if thermostat == nil || err != nil || !validTsoll(param) {
+			http.Error(w, "inval", http.StatusBadRequest)
+			return
+		}
+		_ = m.patchUnit(thermostat.UID, thermostatPatch("setPointTemperature", tsollTemperature(param)))
+		writeText(w, strconv.Itoa(param))
+	case "sethkrboost", "sethkrwindowopen":
+		end, err := strconv.ParseInt(r.Form.Get("endtimestamp"), 10, 64)
+		if thermostat == nil || err != nil {
+			http.Error(w, "inval", http.StatusBadRequest)
+			return
+		}
+		key := map[string]string{"sethkrboost": "boost", "sethkrwindowopen": "windowOpenMode"}[cmd]
+		_ = m.patchUnit(thermostat.UID, thermostatPatch(key, map[string]any{"enabled": end != 0, "endTime": end}))
+		writeText(w, strconv.FormatInt(end, 10))
+	default:
+		http.Error(w, "inval", http.StatusBadRequest)
+	}
+}
+
+func (m *Model) deviceIndex(ain string) int {
+	for i := range m.Devices {
+		if m.Devices[i].Ain == ain || m.Devices[i].UID == ain {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Model) thermostatUnit(d *rest.HelperOverviewDevice) *rest.HelperOverviewUnit {
+	for _, u := range m.deviceUnits(d) {
+		if u.Interfaces.ThermostatInterface != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// ahaDevice derives the AHA representation of the i-th device from its units.
+func (m *Model) ahaDevice(i int) ahaDevice {
+	d := &m.Devices[i]
+	dev := ahaDevice{
+		Identifier:   d.Ain,
+		ID:           16 + i,
+		FwVersion:    d.FirmwareVersion,
+		Manufacturer: d.Manufacturer,
+		ProductName:  d.ProductName,
+		Present:      boolInt(d.IsConnected),
+		Name:         d.Name,
+	}
+
+	battery := deref(d.BatteryValue)
+	batteryLow := boolInt(deref(d.IsBatteryLow))
+	if d.BatteryValue != nil {
+		dev.Battery = &battery
+		dev.BatteryLow = &batteryLow
+	}
+
+	for _, u := range m.deviceUnits(d) {
+		if t := u.Interfaces.TemperatureInterface; t != nil {
+			dev.FunctionBitmask |= bitTempSensor
+			dev.Temperature = &ahaTemperature{Celsius: int(math.Round(float64(deref(t.Celsius)) * 10))}
+			if cfg, ok := m.UnitConfigs[u.UID]; ok && cfg.Interfaces.ThermostatInterface != nil {
+				if off := cfg.Interfaces.ThermostatInterface.TemperatureOffset; off != nil {
+					dev.Temperature.Offset = int(math.Round(float64(deref(off.InternalOffset)) * 10))
+				}
+			}
+		}
+
+		t := u.Interfaces.ThermostatInterface
+		if t == nil {
+			continue
+		}
+		dev.FunctionBitmask |= bitHkr
+		dev.Hkr = &ahaHkr{
+			Tsoll:         temperatureTsoll(t.SetPointTemperature),
+			Komfort:       temperatureTsoll(t.ComfortTemperature),
+			Absenk:        temperatureTsoll(t.ReducedTemperature),
+			Lock:          boolInt(deref(t.IsLockedDeviceApi)),
+			Devicelock:    boolInt(deref(t.IsLockedDeviceLocal)),
+			Batterylow:    batteryLow,
+			Battery:       battery,
+			Summeractive:  boolInt(deref(t.IsSummertimeActive)),
+			Holidayactive: boolInt(deref(t.IsHolidayActive)),
+		}
+		if b := t.Boost; b != nil {
+			dev.Hkr.Boostactive = boolInt(deref(b.Enabled))
+			dev.Hkr.Boostactiveendtime = int64(deref(b.EndTime))
+		}
+		if wo := t.WindowOpenMode; wo != nil {
+			dev.Hkr.Windowopenactiv = boolInt(deref(wo.Enabled))
+			dev.Hkr.Windowopenactiveendtime = int64(deref(wo.EndTime))
+		}
+	}
+	return dev
+}
+
+// temperatureTsoll converts a REST temperature to the AHA format (half degrees, 253 off, 254 on).
+func temperatureTsoll(t *rest.HelperTemperature) int {
+	if t == nil {
+		return 0
+	}
+	switch t.Mode {
+	case rest.HelperTemperatureModeOff:
+		return ahaTempOff
+	case rest.HelperTemperatureModeOn:
+		return ahaTempMax
+	}
+	return int(math.Round(float64(deref(t.Celsius)) * 2))
+}
+
+// tsollTemperature is the inverse of temperatureTsoll.
+func tsollTemperature(v int) map[string]any {
+	switch v {
+	case ahaTempOff:
+		return map[string]any{"mode": rest.HelperTemperatureModeOff}
+	case ahaTempMax:
+		return map[string]any{"mode": rest.HelperTemperatureModeOn}
+	}
+	return map[string]any{"mode": rest.HelperTemperatureModeTemperature, "celsius": float64(v) / 2}
+}
+
+// validTsoll reports whether v is 8-28 °C in half degrees, off or on.
+func validTsoll(v int) bool {
+	return (v >= 16 && v <= 56) || v == ahaTempOff || v == ahaTempMax
+}
+
+func thermostatPatch(key string, value any) []byte {
+	return mustJSON(map[string]any{"interfaces": map[string]any{"thermostatInterface": map[string]any{key: value}}})
+}
+
+func mustJSON(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// readTxBusy marks dev as busy if SetTxBusy left reads for it. The caller must hold s.mu.
+func (s *Server) readTxBusy(dev ahaDevice) ahaDevice {
+	if s.txBusy[dev.Identifier] > 0 {
+		s.txBusy[dev.Identifier]--
+		dev.TxBusy = 1
+	}
+	return dev
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = fmt.Fprint(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = fmt.Fprintln(w, s)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,297 @@
+package fritztest
+
+import (
+	"encoding/json"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+// Model is the state of a fake box. Devices and units use the REST types; the AHA
+// view served by homeautoswitch.lua is derived from them.
+type Model struct {
+	// Box is served by jason_boxinfo.xml. Firmware is derived from Version if empty.
+	// The smart home REST API is only served from FRITZ!OS 8.20 on.
+	Box fritzbox.BoxInfo
+	// UDN is the unique device name served by tr64desc.xml, found via SSDP.
+	UDN string
+	// Repeater leaves the WAN device out of tr64desc.xml, as repeaters and boxes in
+	// repeater mode do.
+	Repeater bool
+
+	Devices []rest.HelperOverviewDevice
+	Units   []rest.HelperOverviewUnit
+	Globals rest.HelperOverviewGlobals
+
+	// RadioBases is the list served by connect/radioBases. A smart home master lists
+	// all radio bases of the mesh, other boxes only themselves.
+	RadioBases []rest.EndpointRadioBases
+
+	// UnitConfigs holds the configuration endpoint view of units, keyed by UID.
+	UnitConfigs map[string]rest.EndpointConfigurationUnit
+
+	// Pages maps data.lua page names to the "data" object of the response.
+	Pages map[string]any
+
+	// Queries maps query.lua expressions, e.g. "landevice:settings/landevice/list(UID,ip)",
+	// to their results.
+	Queries map[string]any
+}
+
+func newModel() Model {
+	return Model{
+		Box: fritzbox.BoxInfo{
+			Model:    "FRITZ!Box 7590",
+			Hardware: "226",
+			Version:  fritzbox.Version{Major: 8, Minor: 20},
+			OEM:      "avm",
+			Language: "de",
+			Country:  "049",
+			Annex:    "B",
+		},
+		UDN:         "uuid:75802409-bccb-40e7-8e6c-3810d5a1b2c3",
+		UnitConfigs: make(map[string]rest.EndpointConfigurationUnit),
+		Pages:       make(map[string]any),
+		Queries:     make(map[string]any),
+	}
+}
+
+// Update calls fn with the model while holding the server lock.
+// fn must not keep references to the model after returning.
+func (s *Server) Update(fn func(m *Model)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.model)
+}
+
+// Unit returns a copy of the overview unit with the given UID.
+func (s *Server) Unit(uid string) (rest.HelperOverviewUnit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var u rest.HelperOverviewUnit
+	p := s.model.unit(uid)
+	if p == nil {
+		return u, false
+	}
+	return u, deepCopy(p, &u) == nil
+}
+
+// UnitConfig returns a copy of the configuration of the unit with the given UID.
+func (s *Server) UnitConfig(uid string) (rest.EndpointConfigurationUnit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var u rest.EndpointConfigurationUnit
+	cfg, ok := s.model.UnitConfigs[uid]
+	if !ok {
+		return u, false
+	}
+	return u, deepCopy(cfg, &u) == nil
+}
+
+// AddThermostat adds a FRITZ!DECT 301 with the given AIN, name and room temperature.
+// The target temperature starts at the room temperature, the presets at 21 and 17 °C.
+// It returns the UID of the thermostat unit, which is the AIN with the suffix "-1".
+func (s *Server) AddThermostat(ain, name string, celsius float64) string {
+	uid := ain + "-1"
+	current := float32(celsius)
+	comfort, reduced := float32(21), float32(17)
+	offset := float32(0)
+	battery := 80
+	yes, no := true, false
+	endTime := 0
+
+	s.Update(func(m *Model) {
+		m.Devices = append(m.Devices, rest.HelperOverviewDevice{
+			UID:                       ain,
+			Ain:                       ain,
+			BatteryState:              rest.HelperOverviewDeviceBatteryStateKnown,
+			BatteryValue:              &battery,
+			FirmwareVersion:           "05.16",
+			Icons:                     []int{},
+			IsBatteryLow:              &no,
+			IsBatteryPowered:          &yes,
+			IsConnected:               true,
+			IsDeviceSubscribedLocally: true,
+			Manufacturer:              "AVM",
+			Name:                      name,
+			ProductCategory:           rest.HelperOverviewDeviceProductCategoryThermostat,
+			ProductName:               "FRITZ!DECT 301",
+			UnitUids:                  []string{uid},
+		})
+
+		m.Units = append(m.Units, rest.HelperOverviewUnit{
+			UID:         uid,
+			Ain:         uid,
+			DeviceUid:   &ain,
+			Icons:       []int{},
+			IsConnected: &yes,
+			Name:        name,
+			ParentUid:   ain,
+			UnitType:    rest.AvmThermostat,
+			Interfaces: rest.IFUnitInterfaces{
+				TemperatureInterface: &rest.IFTemperatureOverview{
+					Celsius: &current,
+					State:   rest.StateGenericStateValid,
+				},
+				ThermostatInterface: &rest.IFThermostatOverview{
+					SetPointTemperature: temperature(current),
+					ComfortTemperature:  temperature(comfort),
+					ReducedTemperature:  temperature(reduced),
+					Boost:               &rest.HelperSpecialModeThermostat{Enabled: &no, EndTime: &endTime},
+					WindowOpenMode:      &rest.HelperSpecialModeThermostat{Enabled: &no, EndTime: &endTime},
+					IsLockedDeviceApi:   &no,
+					IsLockedDeviceLocal: &no,
+					IsHolidayActive:     &no,
+					IsSummertimeActive:  &no,
+					State:               rest.StateGenericStateValid,
+				},
+			},
+		})
+
+		m.UnitConfigs[uid] = rest.EndpointConfigurationUnit{
+			UID:         uid,
+			Ain:         uid,
+			DeviceUid:   &ain,
+			Icons:       []int{},
+			IsConnected: true,
+			Name:        name,
+			ParentUid:   ain,
+			UnitType:    rest.AvmThermostat,
+			Interfaces: rest.IFUnitInterfacesConfig{
+				ThermostatInterface: &rest.IFThermostatConfig{
+					SetPointTemperature:        temperature(current),
+					ComfortTemperature:         temperature(comfort),
+					ReducedTemperature:         temperature(reduced),
+					AdaptiveHeatingModeEnabled: &no,
+					LockedDeviceApiEnabled:     &no,
+					LockedDeviceLocalEnabled:   &no,
+					SummerPeriod:               &rest.HelperSummerPeriod{},
+					HolidayPeriods:             &rest.HelperHolidayPeriods{},
+					TemperatureOffset:          &rest.HelperTemperatureOffset{InternalOffset: &offset, SensorMode: "internal"},
+					State:                      rest.StateGenericStateValid,
+				},
+			},
+		}
+	})
+	return uid
+}
+
+func temperature(celsius float32) *rest.HelperTemperature {
+	return &rest.HelperTemperature{Celsius: &celsius, Mode: rest.HelperTemperatureModeTemperature}
+}
+
+func (m *Model) overview() rest.EndpointOverview {
+	return rest.EndpointOverview{
+		Devices:   nonNil(m.Devices),
+		Globals:   m.Globals,
+		Groups:    []rest.EndpointOverviewGroup{},
+		Templates: []rest.EndpointOverviewGetTemplate{},
+		Triggers:  []rest.EndpointOverviewTrigger{},
+		Units:     nonNil(m.Units),
+	}
+}
+
+func (m *Model) device(uid string) *rest.HelperOverviewDevice {
+	for i := range m.Devices {
+		if m.Devices[i].UID == uid || m.Devices[i].Ain == uid {
+			return &m.Devices[i]
+		}
+	}
+	return nil
+}
+
+func (m *Model) unit(uid string) *rest.HelperOverviewUnit {
+	for i := range m.Units {
+		if m.Units[i].UID == uid {
+			return &m.Units[i]
+		}
+	}
+	return nil
+}
+
+// deviceUnits returns the units whose parent is the device.
+func (m *Model) deviceUnits(d *rest.HelperOverviewDevice) []*rest.HelperOverviewUnit {
+	var units []*rest.HelperOverviewUnit
+	for i := range m.Units {
+		if m.Units[i].ParentUid == d.UID {
+			units = append(units, &m.Units[i])
+		}
+	}
+	return units
+}
+
+// patchUnit merges a JSON patch into the overview and the configuration of a unit.
+// Keys one of the two views does not know are dropped for that view, which keeps
+// shared values such as setPointTemperature in sync.
+func (m *Model) patchUnit(uid string, patch []byte) error {
+	unit := m.unit(uid)
+	if unit == nil {
+		return nil
+	}
+
+	var updated rest.HelperOverviewUnit
+	if err := mergeJSON(unit, patch, &updated); err != nil {
+		return err
+	}
+
+	if cfg, ok := m.UnitConfigs[uid]; ok {
+		var updatedCfg rest.EndpointConfigurationUnit
+		if err := mergeJSON(cfg, patch, &updatedCfg); err != nil {
+			return err
+		}
+		m.UnitConfigs[uid] = updatedCfg
+	}
+
+	*unit = updated
+	return nil
+}
+
+// mergeJSON applies patch to the JSON encoding of v and decodes the result into dst.
+// Objects are merged recursively; null values in patch are ignored.
+func mergeJSON(v any, patch []byte, dst any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var doc, p map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return err
+	}
+	merge(doc, p)
+
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+func merge(dst, patch map[string]any) {
+	for k, v := range patch {
+		switch pv := v.(type) {
+		case nil:
+		case map[string]any:
+			if dv, ok := dst[k].(map[string]any); ok {
+				merge(dv, pv)
+			} else {
+				dst[k] = pv
+			}
+		default:
+			dst[k] = pv
+		}
+	}
+}
+
+func deepCopy(src, dst any) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
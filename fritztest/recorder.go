@@ -0,0 +1,252 @@
+// Package fritztest provides utilities for testing code that uses the fritzbox packages
+// without a real FRITZ!Box.
+package fritztest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+// Mode selects whether a Recorder talks to a real box or replays a cassette.
+type Mode int
+
+const (
+	// ModeAuto replays the cassette if it exists and records a new one otherwise.
+	ModeAuto Mode = iota
+	// ModeRecord forwards requests to the box and records them.
+	ModeRecord
+	// ModeReplay answers requests from the cassette only.
+	ModeReplay
+)
+
+// ErrNoInteraction is returned when replaying a request that is not in the cassette.
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// Cassette is the recorded exchange with a box, stored as JSON.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request with secrets scrubbed. URI is the path and query.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+	Body   string `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response with secrets scrubbed.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+func (r RecordedRequest) key() string {
+	return r.Method + " " + r.URI + "\n" + r.Body
+}
+
+// Recorder is an http.RoundTripper that records exchanges to a cassette file or
+// replays them. Session IDs, challenge responses and passwords are scrubbed with
+// fritzbox.Redact; additional strings such as the username can be added to Secrets.
+//
+// Replayed requests are matched by method, path, query and body after scrubbing.
+// Identical requests are answered in recorded order; the last answer repeats.
+//
+//	rec, err := fritztest.NewRecorder("testdata/thermostats.json", fritztest.ModeAuto)
+//	client.SetHTTPClient(rec.HTTPClient())
+//	defer rec.Stop()
+type Recorder struct {
+	// Transport sends requests while recording. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Secrets are replaced with "REDACTED" in recorded requests and responses.
+	Secrets []string
+
+	path string
+	mode Mode
+
+	mu       sync.Mutex
+	cassette Cassette
+	replay   map[string][]RecordedResponse
+}
+
+// NewRecorder creates a recorder for the cassette at path.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+
+	if mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if r.mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("parse cassette: %w", err)
+		}
+		r.replay = make(map[string][]RecordedResponse)
+		for _, in := range r.cassette.Interactions {
+			k := in.Request.key()
+			r.replay[k] = append(r.replay[k], in.Response)
+		}
+	}
+	return r, nil
+}
+
+// Mode returns the mode the recorder operates in. ModeAuto is resolved on creation.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// HTTPClient returns an http.Client using the recorder, for Client.SetHTTPClient.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop writes the cassette when recording. It does nothing when replaying.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	recReq := RecordedRequest{
+		Method: req.Method,
+		URI:    r.scrub(req.URL.RequestURI()),
+		Body:   r.scrub(string(reqBody)),
+	}
+
+	if r.mode == ModeReplay {
+		return r.replayResponse(req, recReq)
+	}
+	return r.record(req, recReq)
+}
+
+func (r *Recorder) replayResponse(req *http.Request, recReq RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := recReq.key()
+	queue := r.replay[k]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, recReq.Method, recReq.URI)
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		r.replay[k] = queue[1:]
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        resp.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Recorder) record(req *http.Request, recReq RecordedRequest) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// store plain text so cassettes can be reviewed before they are committed
+	stored := body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if plain, err := io.ReadAll(zr); err == nil {
+				stored = plain
+			}
+		}
+	}
+
+	header := http.Header{}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recReq,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       r.scrub(string(stored)),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// scrub removes session IDs, credentials and the configured secrets from s.
+func (r *Recorder) scrub(s string) string {
+	s = fritzbox.Redact(s)
+	for _, secret := range r.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "REDACTED")
+		}
+	}
+	return s
+}
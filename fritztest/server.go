@@ -0,0 +1,606 @@
+package fritztest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+const (
+	defaultSID = "0000000000000000"
+	restPrefix = "/api/v0/smarthome/"
+)
+
+// Server is an in-process fake FRITZ!Box for integration tests. It implements
+// login_sid.lua, the AHA commands of homeautoswitch.lua, the smart home REST API
+// and data.lua/query.lua on top of an editable in-memory Model.
+//
+// Writes through either API change the model, so state set via the REST API can be
+// read back via AHA and vice versa. The server is not a complete emulation; requests
+// it does not know are answered with 400 or 404.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	username  string
+	password  string
+	legacyMD5 bool
+	blockTime int
+	rights    fritzbox.Rights
+	challenge string
+	sessions  map[string]fritzbox.Rights
+	model     Model
+	tfa       *twoFactor
+	restErr   *restError
+	txBusy    map[string]int
+}
+
+// restError is the answer set with SetRestError.
+type restError struct {
+	status int
+	body   string
+}
+
+// NewServer starts a fake box that accepts the given credentials.
+// The username is listed as last used user before login; an empty username
+// accepts any username. Call Close when done.
+func NewServer(username, password string) *Server {
+	s := newServer(username, password)
+	s.Start()
+	return s
+}
+
+// NewTLSServer is like NewServer but serves HTTPS with a self-signed certificate,
+// available as Certificate().
+func NewTLSServer(username, password string) *Server {
+	s := newServer(username, password)
+	s.StartTLS()
+	return s
+}
+
+func newServer(username, password string) *Server {
+	s := &Server{
+		username: username,
+		password: password,
+		rights: fritzbox.Rights{
+			fritzbox.RightDial:     fritzbox.AccessWrite,
+			fritzbox.RightApp:      fritzbox.AccessWrite,
+			fritzbox.RightHomeAuto: fritzbox.AccessWrite,
+			fritzbox.RightBoxAdmin: fritzbox.AccessWrite,
+			fritzbox.RightPhone:    fritzbox.AccessWrite,
+			fritzbox.RightNAS:      fritzbox.AccessWrite,
+		},
+		sessions: make(map[string]fritzbox.Rights),
+		model:    newModel(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login_sid.lua", s.handleLogin)
+	mux.HandleFunc("/jason_boxinfo.xml", s.handleBoxInfo)
+	mux.HandleFunc("/tr64desc.xml", s.handleTR64Desc)
+	mux.HandleFunc("/webservices/homeautoswitch.lua", s.handleAha)
+	mux.HandleFunc("/data.lua", s.handleData)
+	mux.HandleFunc("/query.lua", s.handleQuery)
+	mux.HandleFunc("/twofactor.lua", s.handleTwoFactor)
+	mux.HandleFunc(restPrefix, s.handleRest)
+	s.Server = httptest.NewUnstartedServer(mux)
+	return s
+}
+
+// NewClient returns a client for the server. It is not connected yet.
+func (s *Server) NewClient() *fritzbox.Client {
+	c := fritzbox.New(s.username, s.password)
+	c.BaseUrl = s.URL + "/"
+	return c
+}
+
+// SetLegacyMD5 makes the server hand out MD5 challenges instead of PBKDF2 ones,
+// like boxes before FRITZ!OS 7.24.
+func (s *Server) SetLegacyMD5(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.legacyMD5 = enabled
+}
+
+// SetBlockTime sets the number of seconds the server reports as login block time.
+func (s *Server) SetBlockTime(seconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blockTime = seconds
+}
+
+// SetRights sets the rights of new sessions. All rights are writable by default.
+// Like the real box, the AHA and REST handlers answer 403 if a session lacks HomeAuto.
+func (s *Server) SetRights(r fritzbox.Rights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rights = r
+}
+
+// SetRestError makes the REST API answer every request of a valid session with status
+// and body, e.g. an error list the box would send. A status of 0 restores normal
+// operation.
+func (s *Server) SetRestError(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restErr = nil
+	if status != 0 {
+		s.restErr = &restError{status: status, body: body}
+	}
+}
+
+// SetTxBusy makes the device ain report txbusy, i.e. busy sending a command over DECT,
+// in the next reads AHA reads of it, as the box does until the device has answered.
+func (s *Server) SetTxBusy(ain string, reads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.txBusy == nil {
+		s.txBusy = make(map[string]int)
+	}
+	s.txBusy[ain] = reads
+}
+
+// ExpireSessions invalidates all session IDs, as if the box had timed them out.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]fritzbox.Rights)
+}
+
+// Sessions returns the number of valid session IDs.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// validSID reports whether sid belongs to an active session. The caller must hold s.mu.
+func (s *Server) validSID(sid string) bool {
+	_, ok := s.sessions[sid]
+	return sid != "" && sid != defaultSID && ok
+}
+
+// allowed reports whether the session sid has at least access to right. The caller
+// must hold s.mu.
+func (s *Server) allowed(sid string, right fritzbox.Right, access fritzbox.Access) bool {
+	return s.sessions[sid].Has(right, access)
+}
+
+// loginResponse is the SessionInfo document served by login_sid.lua.
+type loginResponse struct {
+	XMLName   xml.Name      `xml:"SessionInfo"`
+	SID       string        `xml:"SID"`
+	Challenge string        `xml:"Challenge"`
+	BlockTime int           `xml:"BlockTime"`
+	Rights    []loginRights `xml:"Rights"`
+	Users     []loginUser   `xml:"Users>User"`
+}
+
+type loginUser struct {
+	Name string `xml:",chardata"`
+	Last int    `xml:"last,attr,omitempty"`
+}
+
+type loginRights struct {
+	Name   []string `xml:"Name"`
+	Access []int8   `xml:"Access"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := loginResponse{SID: defaultSID}
+	switch {
+	case r.Form.Get("logout") != "":
+		delete(s.sessions, r.Form.Get("sid"))
+	case r.Form.Get("response") != "":
+		if s.checkResponse(r.Form.Get("username"), r.Form.Get("response")) {
+			resp.SID = s.newSession()
+			resp.Rights = s.rightsResponse()
+		}
+	case s.validSID(r.Form.Get("sid")):
+		resp.SID = r.Form.Get("sid")
+		resp.Rights = s.rightsResponse()
+	}
+
+	if resp.SID == defaultSID {
+		s.challenge = s.newChallenge(r.Form.Get("version") == "2")
+		resp.Challenge = s.challenge
+		resp.BlockTime = s.blockTime
+		if s.username != "" {
+			resp.Users = []loginUser{{Name: s.username, Last: 1}}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+// newChallenge creates a challenge. PBKDF2 challenges use few iterations to keep tests fast.
+func (s *Server) newChallenge(pbkdf2 bool) string {
+	if !pbkdf2 || s.legacyMD5 {
+		return randomHex(4)
+	}
+	return fmt.Sprintf("2$10$%s$10$%s", randomHex(16), randomHex(16))
+}
+
+// checkResponse verifies the challenge response against the current challenge.
+// Each challenge can only be used once.
+func (s *Server) checkResponse(username, response string) bool {
+	challenge := s.challenge
+	s.challenge = ""
+	if challenge == "" || (s.username != "" && username != s.username) {
+		return false
+	}
+	return hmac.Equal([]byte(response), []byte(challengeResponse(challenge, s.password)))
+}
+
+func (s *Server) newSession() string {
+	sid := randomHex(8)
+	s.sessions[sid] = s.rights
+	return sid
+}
+
+func (s *Server) rightsResponse() []loginRights {
+	names := make([]string, 0, len(s.rights))
+	for right := range s.rights {
+		names = append(names, string(right))
+	}
+	sort.Strings(names)
+
+	var lr loginRights
+	for _, name := range names {
+		lr.Name = append(lr.Name, name)
+		lr.Access = append(lr.Access, int8(s.rights[fritzbox.Right(name)]))
+	}
+	return []loginRights{lr}
+}
+
+// challengeResponse computes the expected response for an MD5 or PBKDF2 challenge.
+func challengeResponse(challenge, password string) string {
+	parts := strings.Split(challenge, "$")
+	if len(parts) != 5 || parts[0] != "2" {
+		var buf bytes.Buffer
+		for _, c := range utf16.Encode([]rune(challenge + "-" + password)) {
+			if c > 255 {
+				c = '.'
+			}
+			_ = binary.Write(&buf, binary.LittleEndian, c)
+		}
+		return fmt.Sprintf("%s-%x", challenge, md5.Sum(buf.Bytes()))
+	}
+
+	iter1, _ := strconv.Atoi(parts[1])
+	salt1, _ := hex.DecodeString(parts[2])
+	iter2, _ := strconv.Atoi(parts[3])
+	salt2, _ := hex.DecodeString(parts[4])
+	hash := pbkdf2Key(pbkdf2Key([]byte(password), salt1, iter1), salt2, iter2)
+	return fmt.Sprintf("%s$%x", parts[4], hash)
+}
+
+// pbkdf2Key derives a single PBKDF2-HMAC-SHA256 block.
+func pbkdf2Key(password, salt []byte, iter int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+
+	key := append([]byte(nil), u...)
+	for i := 1; i < iter; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(nil)
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// handleRest serves the overview, unit configuration and radio base endpoints of the
+// smart home REST API.
+func (s *Server) handleRest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sid := strings.TrimPrefix(r.Header.Get("Authorization"), "AVM-SID ")
+	if !s.validSID(sid) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	access := fritzbox.AccessWrite
+	if r.Method == http.MethodGet {
+		access = fritzbox.AccessRead
+	}
+	if !s.allowed(sid, fritzbox.RightHomeAuto, access) {
+		writeRestError(w, http.StatusForbidden, rest.CodeNoPermission, "")
+		return
+	}
+	if e := s.restErr; e != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.status)
+		_, _ = io.WriteString(w, e.body)
+		return
+	}
+
+	m := &s.model
+	if !m.Box.Version.AtLeast(fritzbox.Version{Major: 8, Minor: 20}) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.checkTwoFactor(w, r) {
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, restPrefix), "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(path) == 1 && path[0] == "overview":
+		writeJSON(w, http.StatusOK, m.overview())
+	case r.Method == http.MethodGet && len(path) == 2 && path[0] == "overview" && path[1] == "devices":
+		writeJSON(w, http.StatusOK, nonNil(m.Devices))
+	case r.Method == http.MethodGet && len(path) == 2 && path[0] == "overview" && path[1] == "units":
+		writeJSON(w, http.StatusOK, nonNil(m.Units))
+	case r.Method == http.MethodGet && len(path) == 2 && path[0] == "overview" && path[1] == "globals":
+		writeJSON(w, http.StatusOK, m.Globals)
+	case r.Method == http.MethodGet && len(path) == 2 && path[0] == "connect" && path[1] == "radioBases":
+		writeJSON(w, http.StatusOK, nonNil(m.RadioBases))
+	case len(path) == 3 && path[0] == "overview" && path[1] == "devices":
+		if r.Method != http.MethodGet {
+			writeRestError(w, http.StatusMethodNotAllowed, rest.CodeBadPath, "")
+			return
+		}
+		if d := m.device(path[2]); d != nil {
+			writeJSON(w, http.StatusOK, d)
+			return
+		}
+		writeRestError(w, http.StatusNotFound, rest.CodeUIDNotFound, "UID")
+	case len(path) == 3 && path[0] == "overview" && path[1] == "units":
+		s.handleRestUnit(w, r, path[2], false)
+	case len(path) == 3 && path[0] == "configuration" && path[1] == "units":
+		s.handleRestUnit(w, r, path[2], true)
+	default:
+		writeRestError(w, http.StatusNotFound, rest.CodeBadPath, "")
+	}
+}
+
+// handleRestUnit serves GET and PUT for a single unit. The caller must hold s.mu.
+func (s *Server) handleRestUnit(w http.ResponseWriter, r *http.Request, uid string, config bool) {
+	m := &s.model
+	unit := m.unit(uid)
+	if unit == nil {
+		writeRestError(w, http.StatusNotFound, rest.CodeUIDNotFound, "UID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !config {
+			writeJSON(w, http.StatusOK, unit)
+			return
+		}
+		cfg, ok := m.UnitConfigs[uid]
+		if !ok {
+			writeRestError(w, http.StatusNotFound, rest.CodeUIDNotFound, "UID")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg)
+	case http.MethodPut:
+		patch, err := io.ReadAll(r.Body)
+		if err != nil || !json.Valid(patch) {
+			writeRestError(w, http.StatusBadRequest, rest.CodeBadPayload, "")
+			return
+		}
+		if err := m.patchUnit(uid, patch); err != nil {
+			writeRestError(w, http.StatusBadRequest, rest.CodeWrongType, "")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeRestError(w, http.StatusMethodNotAllowed, rest.CodeBadPath, "")
+	}
+}
+
+// boxInfo is the content of jason_boxinfo.xml.
+type boxInfo struct {
+	XMLName  xml.Name `xml:"j:BoxInfo"`
+	NS       string   `xml:"xmlns:j,attr"`
+	Name     string   `xml:"j:Name"`
+	HW       string   `xml:"j:HW"`
+	Version  string   `xml:"j:Version"`
+	Revision string   `xml:"j:Revision"`
+	Serial   string   `xml:"j:Serial"`
+	OEM      string   `xml:"j:OEM"`
+	Lang     string   `xml:"j:Lang"`
+	Annex    string   `xml:"j:Annex"`
+	Lab      *string  `xml:"j:Lab"`
+	Country  string   `xml:"j:Country"`
+	Flags    []string `xml:"j:Flag"`
+}
+
+// handleBoxInfo serves jason_boxinfo.xml from Model.Box. It needs no login.
+func (s *Server) handleBoxInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b := s.model.Box
+	s.mu.Unlock()
+
+	version := b.Firmware
+	if version == "" {
+		version = fmt.Sprintf("%02d.%02d", b.Version.Major, b.Version.Minor)
+	}
+	resp := boxInfo{
+		NS:       "http://jason.avm.de/updatecheck/",
+		Name:     b.Model,
+		HW:       b.Hardware,
+		Version:  version,
+		Revision: b.Revision,
+		Serial:   b.Serial,
+		OEM:      b.OEM,
+		Lang:     b.Language,
+		Annex:    b.Annex,
+		Country:  b.Country,
+		Flags:    b.Flags,
+	}
+	if b.Lab {
+		resp.Lab = new(string)
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+type tr64Desc struct {
+	XMLName       xml.Name `xml:"urn:dslforum-org:device-1-0 root"`
+	SystemVersion struct {
+		Display string `xml:"Display"`
+	} `xml:"systemVersion"`
+	Device struct {
+		DeviceType   string       `xml:"deviceType"`
+		FriendlyName string       `xml:"friendlyName"`
+		Manufacturer string       `xml:"manufacturer"`
+		ModelName    string       `xml:"modelName"`
+		UDN          string       `xml:"UDN"`
+		DeviceList   []tr64Device `xml:"deviceList>device"`
+	} `xml:"device"`
+}
+
+type tr64Device struct {
+	DeviceType string `xml:"deviceType"`
+}
+
+// handleTR64Desc serves the device part of tr64desc.xml from Model.Box, Model.UDN and
+// Model.Repeater, enough to identify the box after discovery. It lists no services.
+func (s *Server) handleTR64Desc(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b, udn, repeater := s.model.Box, s.model.UDN, s.model.Repeater
+	s.mu.Unlock()
+
+	var resp tr64Desc
+	resp.SystemVersion.Display = b.Firmware
+	if resp.SystemVersion.Display == "" {
+		resp.SystemVersion.Display = fmt.Sprintf("%02d.%02d", b.Version.Major, b.Version.Minor)
+	}
+	resp.Device.DeviceType = "urn:dslforum-org:device:InternetGatewayDevice:1"
+	resp.Device.FriendlyName = b.Model
+	resp.Device.Manufacturer = "AVM"
+	resp.Device.ModelName = b.Model
+	resp.Device.UDN = udn
+	resp.Device.DeviceList = []tr64Device{{DeviceType: "urn:dslforum-org:device:LANDevice:1"}}
+	if !repeater {
+		resp.Device.DeviceList = append(resp.Device.DeviceList, tr64Device{DeviceType: "urn:dslforum-org:device:WANDevice:1"})
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+// handleData serves data.lua pages from Model.Pages.
+// Like the real box, an invalid SID is answered with 200 and the default SID.
+func (s *Server) handleData(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sid := r.Form.Get("sid")
+	if !s.validSID(sid) {
+		writeJSON(w, http.StatusOK, map[string]any{"sid": defaultSID})
+		return
+	}
+
+	data, ok := s.model.Pages[r.Form.Get("page")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sid": sid, "data": data})
+}
+
+// handleQuery serves query.lua. Every parameter except sid is looked up in
+// Model.Queries and returned under the parameter name; unknown queries yield "".
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validSID(r.Form.Get("sid")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	resp := make(map[string]any)
+	for key := range r.Form {
+		if key == "sid" {
+			continue
+		}
+		if v, ok := s.model.Queries[r.Form.Get(key)]; ok {
+			resp[key] = v
+		} else {
+			resp[key] = ""
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeRestError writes an ErrorResponse with a single entry.
+func writeRestError(w http.ResponseWriter, status, code int, field string) {
+	entry := map[string]any{"code": code}
+	if field != "" {
+		entry["field"] = field
+	}
+	writeJSON(w, status, map[string]any{"errors": []any{entry}})
+}
+
+// nonNil makes empty lists encode as [] instead of null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
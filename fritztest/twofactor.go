@@ -0,0 +1,102 @@
+package fritztest
+
+import (
+	"net/http"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+// TwoFactorCode is the DTMF code offered by challenges of the server.
+const TwoFactorCode = "*1234567#"
+
+// twoFactor is the state of the second factor confirmation.
+type twoFactor struct {
+	// active is set while a challenge waits for confirmation.
+	active bool
+	// confirmed allows the next write.
+	confirmed bool
+}
+
+// SetTwoFactor makes REST writes require a second factor, like hardened boxes do for
+// sensitive changes. Unconfirmed writes are answered with 403 and error 3008.
+// Challenges are started via twofactor.lua and confirmed with ConfirmTwoFactor; a
+// confirmation allows one write.
+func (s *Server) SetTwoFactor(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled {
+		s.tfa = &twoFactor{}
+	} else {
+		s.tfa = nil
+	}
+}
+
+// ConfirmTwoFactor confirms the running challenge, as if a button on the box was
+// pressed. It reports false if no challenge is running.
+func (s *Server) ConfirmTwoFactor() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tfa == nil || !s.tfa.active {
+		return false
+	}
+	s.tfa.active = false
+	s.tfa.confirmed = true
+	return true
+}
+
+// checkTwoFactor answers an unconfirmed write with error 3008 and consumes the
+// confirmation otherwise. It reports whether the write may proceed. The caller must
+// hold s.mu.
+func (s *Server) checkTwoFactor(w http.ResponseWriter, r *http.Request) bool {
+	if s.tfa == nil || r.Method == http.MethodGet {
+		return true
+	}
+	if !s.tfa.confirmed {
+		writeRestError(w, http.StatusForbidden, rest.Code2FANeeded, "")
+		return false
+	}
+	s.tfa.confirmed = false
+	return true
+}
+
+// handleTwoFactor serves twofactor.lua: tfa_start starts a challenge, tfa_active
+// reports its state.
+func (s *Server) handleTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validSID(r.Form.Get("sid")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if s.tfa == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case r.Form.Has("tfa_start"):
+		if s.tfa.active {
+			writeJSON(w, http.StatusOK, map[string]any{"err": 1})
+			return
+		}
+		s.tfa.active = true
+		s.tfa.confirmed = false
+		writeJSON(w, http.StatusOK, map[string]any{
+			"err":  0,
+			"data": map[string]any{"methods": []string{"button", "dtmf;" + TwoFactorCode}},
+		})
+	case r.Form.Has("tfa_active"):
+		writeJSON(w, http.StatusOK, map[string]any{
+			"err":  0,
+			"data": map[string]any{"active": s.tfa.active, "done": s.tfa.confirmed},
+		})
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,44 @@
+package fritzbox
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetLogger sets the logger used by the client and by the packages that log on their
+// own, such as aha, unsafe and callmonitor. Requests, including those of rest and
+// smart, are logged at debug level, re-logins at info level and problems the client
+// recovers from at warn level. Pass nil to disable logging (the default).
+func (c *Client) SetLogger(l *slog.Logger) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = l
+}
+
+// Logger returns the client's logger. Never nil; discards all records if no logger is set.
+func (c *Client) Logger() *slog.Logger {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.logger == nil {
+		return discardLogger
+	}
+	return c.logger
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+// DiscardLogger returns the logger that drops all records, as returned by Logger if
+// no logger is set. Packages use it when logging is disabled.
+func DiscardLogger() *slog.Logger {
+	return discardLogger
+}
+
+// discardHandler drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,168 @@
+package fritzbox
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements of the requests made with AhaRequest, RestRequest and Do,
+// and thus of all packages built on them. Implementations must be safe for concurrent
+// use and should return quickly.
+type Metrics interface {
+	// ObserveRequest is called once per request, after re-logins and retries.
+	ObserveRequest(RequestStats)
+	// ObserveRelogin is called after each login following a rejected session.
+	// err is the login error, if any.
+	ObserveRelogin(err error)
+}
+
+// RequestStats describes a finished request.
+type RequestStats struct {
+	// API is "aha" for AhaRequest, "rest" for RestRequest and "http" for Do.
+	API    string
+	Method string
+	// Endpoint is the path without IDs, so it can be used as a label: REST paths end
+	// in "{id}" instead of the UID, AHA paths name the command, e.g.
+	// "webservices/homeautoswitch.lua?switchcmd=getdevicelistinfos".
+	Endpoint string
+	// Duration is the time until the response headers arrived, including re-logins
+	// and retries.
+	Duration time.Duration
+	// StatusCode is the status of the last response, or 0 if there was none.
+	StatusCode int
+	// RestCode is the first code of a REST error list, or 0.
+	RestCode int
+	// Err is the error returned to the caller. Non-2xx REST answers are not errors.
+	Err error
+	// BytesSent and BytesReceived count the bodies of all attempts, including re-logins.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// SetMetrics sets the receiver of request measurements. Pass nil to disable (the default).
+func (c *Client) SetMetrics(m Metrics) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+}
+
+// metricsCall collects the measurements of one request. Its counters are fed by send
+// through the request context.
+type metricsCall struct {
+	m     Metrics
+	stats RequestStats
+	start time.Time
+
+	sent     atomic.Int64
+	received atomic.Int64
+	status   atomic.Int32
+	once     sync.Once
+}
+
+type metricsKey struct{}
+
+// startMetrics returns a context carrying a new metricsCall, or ctx and nil if no
+// Metrics is set.
+func (c *Client) startMetrics(ctx context.Context, api, method, endpoint string) (context.Context, *metricsCall) {
+	c.mu.RLock()
+	m := c.metrics
+	c.mu.RUnlock()
+	if m == nil {
+		return ctx, nil
+	}
+
+	mc := &metricsCall{
+		m:     m,
+		stats: RequestStats{API: api, Method: method, Endpoint: endpoint},
+		start: time.Now(),
+	}
+	return context.WithValue(ctx, metricsKey{}, mc), mc
+}
+
+// countRequest adds the request body and the response of an attempt to the call in
+// the request context, if any.
+func countRequest(req *http.Request, resp *http.Response) {
+	mc, _ := req.Context().Value(metricsKey{}).(*metricsCall)
+	if mc == nil {
+		return
+	}
+	if req.ContentLength > 0 {
+		mc.sent.Add(req.ContentLength)
+	}
+	if resp != nil {
+		mc.status.Store(int32(resp.StatusCode))
+		resp.Body = &countingBody{ReadCloser: resp.Body, n: &mc.received}
+	}
+}
+
+// finish reports the request. Only the first call has an effect.
+func (mc *metricsCall) finish(restBody []byte, err error) {
+	mc.once.Do(func() {
+		if mc.stats.Duration == 0 {
+			mc.stats.Duration = time.Since(mc.start)
+		}
+		mc.stats.StatusCode = int(mc.status.Load())
+		if mc.stats.StatusCode >= 400 {
+			if codes := restErrorCodes(restBody); len(codes) > 0 {
+				mc.stats.RestCode = codes[0]
+			}
+		}
+		mc.stats.Err = err
+		mc.stats.BytesSent = mc.sent.Load()
+		mc.stats.BytesReceived = mc.received.Load()
+		mc.m.ObserveRequest(mc.stats)
+	})
+}
+
+// finishAha reports an AHA request once the caller has closed the response body,
+// so that the bytes read are included.
+func (mc *metricsCall) finishAha(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		mc.finish(nil, err)
+		return nil, err
+	}
+	mc.stats.Duration = time.Since(mc.start)
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { mc.finish(nil, nil) }}
+	return resp, nil
+}
+
+// ahaEndpoint names an AHA or data.lua request by its command or page.
+func ahaEndpoint(path string, data Values) string {
+	path = strings.TrimPrefix(path, "/")
+	if cmd := data["switchcmd"]; cmd != "" {
+		return path + "?switchcmd=" + cmd
+	}
+	if page := data["page"]; page != "" {
+		return path + "?page=" + page
+	}
+	return path
+}
+
+// restEndpoint strips the query and replaces everything after the collection with
+// "{id}", e.g. "api/v0/smarthome/overview/units/{id}".
+func restEndpoint(path string) string {
+	path, _, _ = strings.Cut(strings.TrimPrefix(path, "/"), "?")
+	parts := strings.SplitN(path, "/", 6)
+	if len(parts) == 6 {
+		parts[5] = "{id}"
+	}
+	return strings.Join(parts, "/")
+}
+
+// countingBody adds the bytes read to n.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
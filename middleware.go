@@ -0,0 +1,181 @@
+package fritzbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RoundTripFunc sends a request and returns its response.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of every request made by the client, including logins.
+// It can inspect or modify the request before calling next and the response after.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use appends middleware to the chain. The first middleware added is the outermost.
+func (c *Client) Use(mw ...Middleware) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// copy so snapshots taken before this call keep their chain
+	chain := make([]Middleware, 0, len(c.middleware)+len(mw))
+	c.middleware = append(append(chain, c.middleware...), mw...)
+}
+
+// Hooks returns a middleware that calls before ahead of each request and after
+// once the response (or error) is available. Either may be nil.
+func Hooks(before func(*http.Request), after func(*http.Request, *http.Response, error)) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if before != nil {
+				before(req)
+			}
+			resp, err := next(req)
+			if after != nil {
+				after(req, resp, err)
+			}
+			return resp, err
+		}
+	}
+}
+
+// do sends req through the rate limiter and the middleware chain, repeating it as the
+// retry policy allows. Each attempt passes the limiter and the chain again.
+func (cn conn) do(req *http.Request) (*http.Response, error) {
+	if cn.retry != nil {
+		return cn.doRetry(req, cn.send)
+	}
+	return cn.send(req)
+}
+
+// send makes a single attempt at req.
+func (cn conn) send(req *http.Request) (*http.Response, error) {
+	next := RoundTripFunc(cn.http.Do)
+	for i := len(cn.middleware) - 1; i >= 0; i-- {
+		next = cn.middleware[i](next)
+	}
+
+	if cn.limiter != nil {
+		if err := cn.limiter.acquire(req); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := next(req)
+	countRequest(req, resp)
+	if err != nil {
+		if cn.limiter != nil {
+			cn.limiter.release()
+		}
+		cn.logger.Debug("request failed", "method", req.Method, "path", req.URL.Path,
+			"duration", time.Since(start), "error", err)
+		return nil, err
+	}
+	if cn.limiter != nil {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: cn.limiter.release}
+	}
+	cn.logger.Debug("request", "method", req.Method, "path", req.URL.Path,
+		"status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
+}
+
+const redacted = "REDACTED"
+
+// redactPatterns match a prefix and, as the last group, the secret to replace.
+var redactPatterns = []*regexp.Regexp{
+	// query strings and form bodies
+	regexp.MustCompile(`(?i)\b(sid|response|password)=([^&\s"]*)`),
+	// Authorization header
+	regexp.MustCompile(`(AVM-SID )([0-9a-fA-F]+)`),
+	// quoted parameters of a Digest Authorization header, sent for TR-064
+	regexp.MustCompile(`(?i)\b((?:username|response|cnonce)=")([^"]*)`),
+	// login_sid.lua
+	regexp.MustCompile(`(<SID>)([^<]*)`),
+	// JSON, e.g. data.lua and query.lua
+	regexp.MustCompile(`(?i)("(?:sid|response|password)"\s*:\s*")([^"]*)`),
+}
+
+// Redact replaces session IDs, passwords and challenge responses in s, and the user
+// name and client nonce of Digest authentication.
+// The all-zero SID is kept since it only signals a missing session.
+func Redact(s string) string {
+	for _, re := range redactPatterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			sub := re.FindStringSubmatch(m)
+			if sub[2] == "" || sub[2] == defaultSID {
+				return m
+			}
+			// the secret is always the end of the match
+			return m[:len(m)-len(sub[2])] + redacted
+		})
+	}
+	return s
+}
+
+// maxDebugBody limits how much of each body DebugLogger writes.
+const maxDebugBody = 4096
+
+// DebugLogger returns a middleware that writes each request and response to w,
+// with session IDs, passwords and challenge responses redacted.
+// Bodies are truncated to 4 KiB.
+func DebugLogger(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			var reqBody []byte
+			if req.Body != nil && req.GetBody != nil {
+				if rc, err := req.GetBody(); err == nil {
+					reqBody, _ = io.ReadAll(rc)
+					rc.Close()
+				}
+			}
+
+			start := time.Now()
+			resp, err := next(req)
+			elapsed := time.Since(start)
+
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, "--> %s %s\n", req.Method, Redact(req.URL.String()))
+			for k, v := range req.Header {
+				fmt.Fprintf(&buf, "%s: %s\n", k, Redact(strings.Join(v, ", ")))
+			}
+			if len(reqBody) > 0 {
+				fmt.Fprintf(&buf, "\n%s\n", Redact(truncate(reqBody)))
+			}
+
+			if err != nil {
+				fmt.Fprintf(&buf, "<-- error after %s: %s\n\n", elapsed.Round(time.Millisecond), Redact(err.Error()))
+			} else {
+				fmt.Fprintf(&buf, "<-- %s (%s)\n", resp.Status, elapsed.Round(time.Millisecond))
+				respBody, rerr := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				resp.Body = io.NopCloser(bytes.NewReader(respBody))
+				if rerr != nil {
+					fmt.Fprintf(&buf, "read body: %s\n", rerr)
+				} else if len(respBody) > 0 {
+					fmt.Fprintf(&buf, "\n%s\n", Redact(truncate(respBody)))
+				}
+				buf.WriteString("\n")
+			}
+
+			mu.Lock()
+			_, _ = w.Write(buf.Bytes())
+			mu.Unlock()
+			return resp, err
+		}
+	}
+}
+
+func truncate(b []byte) string {
+	if len(b) <= maxDebugBody {
+		return string(b)
+	}
+	return string(b[:maxDebugBody]) + fmt.Sprintf("... (%d bytes)", len(b))
+}
@@ -0,0 +1,206 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ErrNoMaster is returned when no box of a Pool is the smart home master.
+var ErrNoMaster = errors.New("no smart home master in pool")
+
+// radioBasesPath lists the smart home radio bases. Only the master lists all of them.
+const radioBasesPath = "api/v0/smarthome/connect/radioBases"
+
+// Pool holds clients for the boxes of one site, e.g. a FRITZ!Box and its mesh
+// repeaters. Smart home calls go to the smart home master, which knows all devices;
+// per-box calls such as logs or traffic statistics can be fanned out with FanOut.
+//
+//	pool := fritzbox.NewPool()
+//	pool.Add("main", fritzbox.New(user, pass))
+//	pool.Add("garage", garageClient)
+//	if err := pool.Connect(ctx); err != nil { ... }
+//	defer pool.Close()
+//
+//	master, err := pool.Master(ctx)
+//	thermostats, err := smart.GetAllThermostats(master)
+//
+//	logs, err := fritzbox.FanOutMerge(ctx, pool, unsafe.GetEventLog)
+//
+// A Pool is safe for concurrent use.
+type Pool struct {
+	mu      sync.RWMutex
+	names   []string
+	clients map[string]*Client
+	master  string
+}
+
+// NewPool returns an empty pool.
+func NewPool() *Pool {
+	return &Pool{clients: make(map[string]*Client)}
+}
+
+// Add adds a client under name, replacing a client with the same name.
+func (p *Pool) Add(name string, c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.clients[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.clients[name] = c
+	if p.master == name {
+		p.master = ""
+	}
+}
+
+// Client returns the client added under name, or nil.
+func (p *Pool) Client(name string) *Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clients[name]
+}
+
+// Names returns the names of all boxes in the order they were added.
+func (p *Pool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.names...)
+}
+
+// Connect connects all clients concurrently. Boxes that fail are reported in the
+// joined error; the others stay connected.
+func (p *Pool) Connect(ctx context.Context) error {
+	_, err := FanOut(ctx, p, func(c *Client) (struct{}, error) {
+		return struct{}{}, c.Connect()
+	})
+	return err
+}
+
+// Close closes all clients.
+func (p *Pool) Close() error {
+	_, err := FanOut(context.Background(), p, func(c *Client) (struct{}, error) {
+		return struct{}{}, c.Close()
+	})
+	return err
+}
+
+// SetMaster marks the box added under name as the smart home master, skipping detection.
+func (p *Pool) SetMaster(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.clients[name]; !ok {
+		return fmt.Errorf("unknown box %q", name)
+	}
+	p.master = name
+	return nil
+}
+
+// Master returns the client of the smart home master. On first use, the master is
+// detected by asking every box for its radio bases: only the master reports one
+// flagged as isSmarthomeMaster. The result is cached until the master's client is
+// replaced with Add.
+func (p *Pool) Master(ctx context.Context) (*Client, error) {
+	p.mu.RLock()
+	name := p.master
+	p.mu.RUnlock()
+	if name != "" {
+		return p.Client(name), nil
+	}
+
+	results, err := FanOut(ctx, p, isSmarthomeMaster)
+	for _, r := range results {
+		if r.Value {
+			p.mu.Lock()
+			p.master = r.Box
+			p.mu.Unlock()
+			return p.Client(r.Box), nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Join(ErrNoMaster, err)
+	}
+	return nil, ErrNoMaster
+}
+
+// isSmarthomeMaster reports whether c lists a radio base flagged as master.
+// Other boxes only list themselves, never flagged.
+func isSmarthomeMaster(c *Client) (bool, error) {
+	body, status, err := c.RestGet(radioBasesPath)
+	if err != nil {
+		return false, err
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("radio bases: unexpected status %d", status)
+	}
+
+	var bases []struct {
+		IsSmarthomeMaster bool `json:"isSmarthomeMaster"`
+	}
+	if err := json.Unmarshal(body, &bases); err != nil {
+		return false, fmt.Errorf("parse radio bases: %w", err)
+	}
+	for _, b := range bases {
+		if b.IsSmarthomeMaster {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Tagged is a value returned by the box added under the name Box.
+type Tagged[T any] struct {
+	Box   string
+	Value T
+}
+
+// FanOut calls fn concurrently with the client of every box, bound to ctx via
+// WithContext. Results are returned in pool order. Boxes that fail are left out of
+// the results and reported in the joined error, so partial results are usable.
+func FanOut[T any](ctx context.Context, p *Pool, fn func(c *Client) (T, error)) ([]Tagged[T], error) {
+	p.mu.RLock()
+	names := append([]string(nil), p.names...)
+	clients := make([]*Client, len(names))
+	for i, name := range names {
+		clients[i] = p.clients[name]
+	}
+	p.mu.RUnlock()
+
+	values := make([]T, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = fn(clients[i].WithContext(ctx))
+		}(i)
+	}
+	wg.Wait()
+
+	var results []Tagged[T]
+	for i, name := range names {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("%s: %w", name, errs[i])
+			continue
+		}
+		results = append(results, Tagged[T]{Box: name, Value: values[i]})
+	}
+	return results, errors.Join(errs...)
+}
+
+// FanOutMerge is like FanOut for functions returning lists. The lists are
+// concatenated in pool order and each entry is tagged with its box.
+func FanOutMerge[T any](ctx context.Context, p *Pool, fn func(c *Client) ([]T, error)) ([]Tagged[T], error) {
+	lists, err := FanOut(ctx, p, fn)
+
+	var merged []Tagged[T]
+	for _, l := range lists {
+		for _, v := range l.Value {
+			merged = append(merged, Tagged[T]{Box: l.Box, Value: v})
+		}
+	}
+	return merged, err
+}
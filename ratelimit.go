@@ -0,0 +1,259 @@
+package fritzbox
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority orders requests waiting for the rate limiter. Higher priorities are
+// served first; requests of equal priority in arrival order.
+type Priority int
+
+const (
+	// PriorityLow is meant for background polling.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default for reads.
+	PriorityNormal Priority = 0
+	// PriorityHigh is the default for logins and writes, so interactive changes are
+	// not starved by polling.
+	PriorityHigh Priority = 1
+)
+
+// Limits configures client-side rate limiting. The zero value disables it.
+type Limits struct {
+	// Rate is the sustained number of requests per second. Zero disables the token bucket.
+	Rate float64
+	// Burst is the number of requests that may be sent at once after a pause. Defaults to 1.
+	Burst int
+	// MaxInFlight caps the number of concurrent requests. Zero means no cap.
+	// A request is in flight until its response body is closed.
+	MaxInFlight int
+	// PathPriority sets the priority of requests by path prefix, e.g.
+	// {"api/v0/smarthome/overview": PriorityLow}. The longest matching prefix wins.
+	// Priorities set with WithPriority take precedence.
+	PathPriority map[string]Priority
+}
+
+type priorityKey struct{}
+
+type writeKey struct{}
+
+// WithPriority returns a context that makes requests sent with it use priority p.
+//
+//	poller := client.WithContext(fritzbox.WithPriority(ctx, fritzbox.PriorityLow))
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// withWrite marks the requests sent with ctx as writes, which default to PriorityHigh.
+func withWrite(ctx context.Context, write bool) context.Context {
+	if !write {
+		return ctx
+	}
+	return context.WithValue(ctx, writeKey{}, true)
+}
+
+// SetLimits sets the rate limits for all requests of the client, including logins.
+// Requests already waiting keep the previous limits.
+func (c *Client) SetLimits(l Limits) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l.Rate <= 0 && l.MaxInFlight <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = newLimiter(l)
+}
+
+// QueuedRequests returns the number of requests waiting for the current limits set
+// with SetLimits, e.g. to shed polling when the box can't keep up.
+func (c *Client) QueuedRequests() int {
+	c = c.root()
+	c.mu.RLock()
+	l := c.limiter
+	c.mu.RUnlock()
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters.Len()
+}
+
+// limiter is a token bucket combined with a semaphore. Waiting requests are granted
+// in priority order.
+type limiter struct {
+	limits Limits
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	inFlight int
+	waiters  waiterHeap
+	seq      uint64
+	timer    *time.Timer
+}
+
+func newLimiter(l Limits) *limiter {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return &limiter{limits: l, tokens: float64(l.Burst), last: time.Now()}
+}
+
+// priority returns the priority of req.
+func (l *limiter) priority(req *http.Request) Priority {
+	ctx := req.Context()
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	best, p := -1, PriorityNormal
+	for prefix, pp := range l.limits.PathPriority {
+		if strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best, p = len(prefix), pp
+		}
+	}
+	if best >= 0 {
+		return p
+	}
+
+	if write, _ := ctx.Value(writeKey{}).(bool); write || strings.HasSuffix(path, "login_sid.lua") {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// acquire blocks until req may be sent. The caller must call release afterwards.
+func (l *limiter) acquire(req *http.Request) error {
+	w := &waiter{priority: l.priority(req), ready: make(chan struct{})}
+
+	l.mu.Lock()
+	l.seq++
+	w.seq = l.seq
+	heap.Push(&l.waiters, w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-req.Context().Done():
+		l.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&l.waiters, w.index)
+			l.mu.Unlock()
+			return req.Context().Err()
+		}
+		l.mu.Unlock()
+		// granted concurrently with the cancellation
+		l.release()
+		return req.Context().Err()
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.dispatch()
+}
+
+// dispatch grants waiting requests while slots and tokens are available.
+// The caller must hold l.mu.
+func (l *limiter) dispatch() {
+	for l.waiters.Len() > 0 {
+		if l.limits.MaxInFlight > 0 && l.inFlight >= l.limits.MaxInFlight {
+			return
+		}
+		if l.limits.Rate > 0 {
+			now := time.Now()
+			l.tokens += now.Sub(l.last).Seconds() * l.limits.Rate
+			l.tokens = min(l.tokens, float64(l.limits.Burst))
+			l.last = now
+			if l.tokens < 1 {
+				l.wakeIn(time.Duration((1 - l.tokens) / l.limits.Rate * float64(time.Second)))
+				return
+			}
+			l.tokens--
+		}
+
+		w := heap.Pop(&l.waiters).(*waiter)
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// wakeIn schedules a dispatch once the next token is available.
+func (l *limiter) wakeIn(d time.Duration) {
+	if l.timer != nil {
+		return
+	}
+	l.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+// releaseBody calls release once the body is closed, e.g. to free the limiter slot
+// of a response.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// waiterHeap orders waiters by priority, then arrival.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
@@ -28,7 +28,7 @@ func GetConfigurationDeviceByUID(c *fritzbox.Client, uid string) (*EndpointConfi
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointConfigurationDevice
@@ -45,7 +45,7 @@ func PutConfigurationDeviceByUID(c *fritzbox.Client, uid string, data *EndpointC
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -61,7 +61,7 @@ func DeleteConfigurationDeviceByUID(c *fritzbox.Client, uid string) error {
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -76,7 +76,7 @@ func GetConfigurationUnitByUID(c *fritzbox.Client, uid string) (*EndpointConfigu
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointConfigurationUnit
@@ -96,7 +96,7 @@ func PutConfigurationUnitByUID(c *fritzbox.Client, uid string, data *EndpointCon
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -113,7 +113,7 @@ func PostConfigurationGroup(c *fritzbox.Client, name string, data *EndpointConfi
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result CreateGroupResponse
@@ -130,7 +130,7 @@ func GetConfigurationGroupByUID(c *fritzbox.Client, uid string) (*EndpointConfig
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointConfigurationGroup
@@ -147,7 +147,7 @@ func PutConfigurationGroupByUID(c *fritzbox.Client, uid string, data *EndpointCo
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -161,7 +161,7 @@ func DeleteConfigurationGroupByUID(c *fritzbox.Client, uid string) error {
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -177,7 +177,7 @@ func PostConfigurationTemplate(c *fritzbox.Client, name string, data *EndpointCo
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result CreateTemplateResponse
@@ -197,7 +197,7 @@ func GetConfigurationTemplateByUID(c *fritzbox.Client, uid string) (*EndpointCon
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointConfigurationGetTemplate
@@ -214,7 +214,7 @@ func PutConfigurationTemplateByUID(c *fritzbox.Client, uid string, data *Endpoin
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -226,7 +226,7 @@ func DeleteConfigurationTemplateByUID(c *fritzbox.Client, uid string) error {
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -240,7 +240,7 @@ func GetConfigurationTemplateCapabilities(c *fritzbox.Client) (*EndpointConfigur
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointConfigurationGetTemplateCapabilities
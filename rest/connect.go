@@ -28,7 +28,7 @@ func GetRadioBasesList(c *fritzbox.Client) ([]EndpointRadioBases, error) {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []EndpointRadioBases
@@ -47,7 +47,7 @@ func GetRadioBaseBySerial(c *fritzbox.Client, serial string) (*EndpointRadioBase
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointRadioBases
@@ -67,7 +67,7 @@ func GetSubscriptionStateByUID(c *fritzbox.Client, uid string) (*EndpointSubscri
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointSubscriptionState
@@ -88,7 +88,7 @@ func PostStartSubscriptionBySerial(c *fritzbox.Client, serial string, data *Endp
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result StartSubscriptionResponse
@@ -105,7 +105,7 @@ func PostStopSubscriptionBySerial(c *fritzbox.Client, serial string) error {
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -119,7 +119,7 @@ func PostResetCodeBySerial(c *fritzbox.Client, serial string, data *EndpointRese
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -133,7 +133,7 @@ func PostInstallCodeBySerial(c *fritzbox.Client, serial string, data *EndpointIn
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+// Error codes returned by the FRITZ!Box in ErrorList entries.
+const (
+	// property-based-fails
+	CodeBadValue          = 1000
+	CodeWrongType         = 1001
+	CodeOutOfRange        = 1002
+	CodeTooShort          = 1003
+	CodeTooLong           = 1004
+	CodeInvalidCharacters = 1005
+	CodeWrongLeadChar     = 1006
+	CodeWrongEndChar      = 1007
+	CodeInvalidFormat     = 1008
+	CodeMissingData       = 1009
+
+	// collection-based-fails
+	CodeInvalidCollection = 2000
+	CodeUIDNotFound       = 2001
+	CodeCollectionFull    = 2002
+	CodeListEmpty         = 2003
+	CodeItemNotValid      = 2004
+	CodeNotDeletable      = 2005
+	CodeAlreadyExists     = 2006
+	CodeItemConflict      = 2007
+
+	// permissions/functionality-based-fails
+	CodePermissions         = 3000
+	CodeNoPermission        = 3001
+	CodeBadPath             = 3002
+	CodeBadPayload          = 3003
+	CodeBadKey              = 3004
+	CodeNotSupported        = 3005
+	CodeBusy                = 3006
+	CodeNotActive           = 3007
+	Code2FANeeded           = 3008
+	Code2FABusy             = 3009
+	Code2FABlocked          = 3010
+	CodeNotSupportedVersion = 3011
+
+	// other errors
+	CodeInternal = 4000
+)
+
+// Sentinel errors for use with errors.Is on an *APIError.
+var (
+	ErrBadValue     = errors.New("bad value")
+	ErrOutOfRange   = errors.New("out of range")
+	ErrUIDNotFound  = errors.New("uid not found")
+	ErrNoPermission = errors.New("no permission")
+	ErrNotSupported = errors.New("not supported")
+	ErrBusy         = errors.New("busy")
+	ErrInternal     = errors.New("internal error")
+)
+
+// The second factor errors are shared with the root package, so errors.Is matches
+// them in errors of RestRequest callers too.
+var (
+	Err2FANeeded  = fritzbox.ErrTwoFactorRequired
+	Err2FABusy    = fritzbox.ErrTwoFactorBusy
+	Err2FABlocked = fritzbox.ErrTwoFactorBlocked
+)
+
+var codeSentinels = map[int]error{
+	CodeBadValue:            ErrBadValue,
+	CodeOutOfRange:          ErrOutOfRange,
+	CodeUIDNotFound:         ErrUIDNotFound,
+	CodeNoPermission:        ErrNoPermission,
+	CodeNotSupported:        ErrNotSupported,
+	CodeNotSupportedVersion: ErrNotSupported,
+	CodeBusy:                ErrBusy,
+	Code2FANeeded:           Err2FANeeded,
+	Code2FABusy:             Err2FABusy,
+	Code2FABlocked:          Err2FABlocked,
+	CodeInternal:            ErrInternal,
+}
+
+// APIError is returned when the REST API answers with an unexpected status.
+// Code, Field and Message are taken from the first entry of the error list, if any.
+//
+// Use errors.Is with the sentinel errors to check for a category:
+//
+//	if errors.Is(err, rest.ErrUIDNotFound) {
+//	    // device was removed
+//	}
+type APIError struct {
+	StatusCode int
+	Code       int
+	Field      string
+	Message    string
+	Extension  map[string]interface{}
+
+	// Errors holds all entries of the error list sent by the box.
+	Errors ErrorList
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, string(e.Body))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "unexpected status %d: code %d", e.StatusCode, e.Code)
+	if e.Field != "" {
+		fmt.Fprintf(&sb, " (field %s)", e.Field)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	} else if sentinel, ok := codeSentinels[e.Code]; ok {
+		fmt.Fprintf(&sb, ": %s", sentinel)
+	}
+	return sb.String()
+}
+
+// Is reports whether any entry of the error list matches target.
+func (e *APIError) Is(target error) bool {
+	for _, item := range e.Errors {
+		if codeSentinels[item.Code] == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCode reports whether any entry of the error list has the given code.
+func (e *APIError) HasCode(code int) bool {
+	for _, item := range e.Errors {
+		if item.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIError decodes the error response body into an *APIError.
+// Bodies that are not a valid ErrorResponse are kept in Body only.
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: status, Body: body}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Errors == nil || len(*resp.Errors) == 0 {
+		return apiErr
+	}
+
+	apiErr.Errors = *resp.Errors
+	first := apiErr.Errors[0]
+	apiErr.Code = first.Code
+	if first.Field != nil {
+		apiErr.Field = *first.Field
+	}
+	if first.Message != nil {
+		apiErr.Message = *first.Message
+	}
+	if first.Extension != nil {
+		apiErr.Extension = *first.Extension
+	}
+	return apiErr
+}
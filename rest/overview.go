@@ -37,7 +37,7 @@ func GetOverview(c *fritzbox.Client) (*EndpointOverview, error) {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointOverview
@@ -57,7 +57,7 @@ func GetOverviewDevicesList(c *fritzbox.Client) ([]HelperOverviewDevice, error)
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []HelperOverviewDevice
@@ -76,7 +76,7 @@ func GetOverviewDeviceByUID(c *fritzbox.Client, uid string) (*HelperOverviewDevi
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result HelperOverviewDevice
@@ -97,7 +97,7 @@ func GetOverviewGroupsList(c *fritzbox.Client) ([]EndpointOverviewGroup, error)
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []EndpointOverviewGroup
@@ -114,7 +114,7 @@ func GetOverviewGroupByUID(c *fritzbox.Client, uid string) (*EndpointOverviewGro
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointOverviewGroup
@@ -135,7 +135,7 @@ func GetOverviewUnitsList(c *fritzbox.Client) ([]HelperOverviewUnit, error) {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []HelperOverviewUnit
@@ -152,7 +152,7 @@ func GetOverviewUnitByUID(c *fritzbox.Client, uid string) (*HelperOverviewUnit,
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result HelperOverviewUnit
@@ -171,7 +171,7 @@ func PutOverviewUnit(c *fritzbox.Client, uid string, data *EndpointOverviewPutUn
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -185,7 +185,7 @@ func GetOverviewTemplatesList(c *fritzbox.Client) ([]EndpointOverviewGetTemplate
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []EndpointOverviewGetTemplate
@@ -202,7 +202,7 @@ func GetOverviewTemplateByUID(c *fritzbox.Client, uid string) (*EndpointOverview
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointOverviewGetTemplate
@@ -221,7 +221,7 @@ func PostOverviewTemplate(c *fritzbox.Client, uid string, data *EndpointOverview
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -235,7 +235,7 @@ func GetOverviewTriggersList(c *fritzbox.Client) ([]EndpointOverviewTrigger, err
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result []EndpointOverviewTrigger
@@ -252,7 +252,7 @@ func GetOverviewTriggerByUID(c *fritzbox.Client, uid string) (*EndpointOverviewT
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result EndpointOverviewTrigger
@@ -270,7 +270,7 @@ func PutOverviewTrigger(c *fritzbox.Client, uid string, enabled bool) error {
 		return err
 	}
 	if status != http.StatusOK && status != http.StatusNoContent {
-		return fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return newAPIError(status, body)
 	}
 	return nil
 }
@@ -344,7 +344,7 @@ func GetOverviewGlobals(c *fritzbox.Client) (*HelperOverviewGlobals, error) {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", status, string(body))
+		return nil, newAPIError(status, body)
 	}
 
 	var result HelperOverviewGlobals
@@ -0,0 +1,241 @@
+package fritzbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// restCodeBusy is the REST error code the box sends when it cannot handle a request right now.
+const restCodeBusy = 3006
+
+// txBusy marks a device in an AHA answer that is still sending a command over DECT,
+// so the state read may be outdated.
+var txBusy = []byte("<txbusy>1</txbusy>")
+
+// RetryPolicy configures how requests failing for a transient reason are repeated.
+// Transient are connection errors while the box reboots, HTTP 503 during firmware
+// operations, REST error 3006 (busy) and AHA answers reporting a DECT device as txbusy.
+//
+// Failures that show the box did not process the request, such as a refused connection
+// or a busy answer, are retried for all requests. Failures that leave this open, such
+// as a connection reset while waiting for the response, and txbusy answers are only
+// retried for reads and for writes marked with WithIdempotent, so a command is not
+// applied twice.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles for every further retry.
+	// Defaults to 250ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Defaults to 10s.
+	MaxDelay time.Duration
+	// Retryable, if set, is asked about failures the policy does not consider transient.
+	// resp is nil if err is set; its body can be read and is restored afterwards.
+	// Retryable must check the idempotency of req itself.
+	Retryable func(req *http.Request, resp *http.Response, err error) bool
+	// OnRetry, if set, is called before waiting for the next attempt.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	Request *http.Request
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+	// Delay is the time until the next attempt.
+	Delay time.Duration
+	// StatusCode is the status of the failed response, or 0 if Err is set.
+	StatusCode int
+	Err        error
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent returns a context that marks the writes sent with it as safe to repeat,
+// e.g. because they set an absolute value. The retry policy then also retries them
+// after failures that leave open whether the box applied the write.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// SetRetryPolicy sets the retry policy for all requests of the client, including logins.
+// Pass the zero RetryPolicy to disable retries, the default.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p.MaxAttempts < 2 {
+		c.retry = nil
+		return
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 250 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	c.retry = &p
+}
+
+// delay returns the backoff before the retry following attempt, with jitter so clients
+// failing together do not retry in lockstep.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// shouldRetry reports whether the outcome of req is worth another attempt.
+// Responses that need a look at the body are buffered, so it stays readable. The
+// original body is only closed with the buffered one, so the limiter slot stays held.
+func (p *RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		if notSent(err) || (ambiguous(err) && idempotent(req)) {
+			return true
+		}
+		return p.Retryable != nil && p.Retryable(req, nil, err)
+	}
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode <= 299
+	// only XML answers can report txbusy, so other bodies aren't buffered for it
+	checkTxBusy := ok && idempotent(req) && strings.Contains(resp.Header.Get("Content-Type"), "xml")
+	if ok && !checkTxBusy && p.Retryable == nil {
+		return false
+	}
+
+	orig := resp.Body
+	body, rerr := io.ReadAll(orig)
+	resp.Body = replayBody{bytes.NewReader(body), orig}
+	if rerr != nil {
+		return false
+	}
+	if !ok && (resp.StatusCode == http.StatusServiceUnavailable || isBusy(body)) {
+		return true
+	}
+	if checkTxBusy && bytes.Contains(body, txBusy) {
+		return true
+	}
+	if p.Retryable == nil {
+		return false
+	}
+	retry := p.Retryable(req, resp, nil)
+	resp.Body = replayBody{bytes.NewReader(body), orig}
+	return retry
+}
+
+// replayBody serves a buffered body and closes the original one.
+type replayBody struct {
+	*bytes.Reader
+	io.Closer
+}
+
+// notSent reports whether err shows that the request never reached the box.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// ambiguous reports whether err is a connection failure after which the box may or
+// may not have processed the request.
+func ambiguous(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// idempotent reports whether req may be sent twice: reads unless they carry a command,
+// and writes marked with WithIdempotent.
+func idempotent(req *http.Request) bool {
+	ctx := req.Context()
+	if ok, _ := ctx.Value(idempotentKey{}).(bool); ok {
+		return true
+	}
+	if write, _ := ctx.Value(writeKey{}).(bool); write {
+		return false
+	}
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// isBusy reports whether body is a REST error list containing the busy code.
+func isBusy(body []byte) bool {
+	return slices.Contains(restErrorCodes(body), restCodeBusy)
+}
+
+// restErrorCodes returns the codes of a REST error list, or nil if body is none.
+func restErrorCodes(body []byte) []int {
+	var resp struct {
+		Errors []struct {
+			Code int `json:"code"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	codes := make([]int, 0, len(resp.Errors))
+	for _, e := range resp.Errors {
+		codes = append(codes, e.Code)
+	}
+	return codes
+}
+
+// doRetry sends req with send until it succeeds, fails for good or the attempts of
+// the policy are used up.
+func (cn conn) doRetry(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	p := cn.retry
+	for attempt := 1; ; attempt++ {
+		resp, err := send(req)
+		if attempt >= p.MaxAttempts || !p.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		ev := RetryEvent{Request: req, Attempt: attempt, Delay: p.delay(attempt), Err: err}
+		if resp != nil {
+			ev.StatusCode = resp.StatusCode
+			resp.Body.Close()
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ev)
+		}
+		cn.logger.Debug("retrying request", "method", req.Method, "path", req.URL.Path,
+			"attempt", attempt, "status", ev.StatusCode, "error", err, "delay", ev.Delay)
+
+		t := time.NewTimer(ev.Delay)
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInsufficientRights is returned when the logged-in user lacks the rights for an operation.
+var ErrInsufficientRights = errors.New("insufficient rights")
+
+// Right is a permission area of a FRITZ!Box user, as reported by login_sid.lua.
+type Right string
+
+const (
+	RightDial     Right = "Dial"
+	RightApp      Right = "App"
+	RightHomeAuto Right = "HomeAuto"
+	RightBoxAdmin Right = "BoxAdmin"
+	RightPhone    Right = "Phone"
+	RightNAS      Right = "NAS"
+)
+
+// Access is the access level of a right.
+type Access int8
+
+const (
+	AccessNone  Access = 0
+	AccessRead  Access = 1
+	AccessWrite Access = 2
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessNone:
+		return "none"
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	default:
+		return fmt.Sprintf("Access(%d)", int8(a))
+	}
+}
+
+// Rights maps each right of the logged-in user to its access level.
+// Rights that are not present have AccessNone.
+type Rights map[Right]Access
+
+// Has reports whether the right is granted with at least the given access level.
+func (r Rights) Has(right Right, access Access) bool {
+	return r[right] >= access
+}
+
+// Check returns an error wrapping ErrInsufficientRights if the right is not
+// granted with at least the given access level.
+//
+// Useful to verify a restricted user at startup:
+//
+//	if err := client.Rights().Check(fritzbox.RightHomeAuto, fritzbox.AccessWrite); err != nil {
+//	    log.Fatal(err)
+//	}
+func (r Rights) Check(right Right, access Access) error {
+	if r.Has(right, access) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires %s access, have %s", ErrInsufficientRights, right, access, r[right])
+}
+
+// Rights returns the rights of the logged-in user.
+// Returns an empty map if the client is not connected.
+func (c *Client) Rights() Rights {
+	c = c.root()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.session == nil {
+		return Rights{}
+	}
+	return c.session.rights()
+}
+
+// SetRightsCheck enables or disables checking the user's rights before each request.
+// When enabled, requests to smart home endpoints (REST and AHA) require HomeAuto and
+// requests to data.lua, query.lua and the generic REST API require BoxAdmin.
+// Reading needs read access, everything else write access.
+// Requests failing the check return an error wrapping ErrInsufficientRights without
+// contacting the box.
+func (c *Client) SetRightsCheck(enabled bool) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkRights = enabled
+}
+
+// pathRights maps request paths to the right needed to use them.
+var pathRights = []struct {
+	prefix string
+	right  Right
+}{
+	{"api/v0/smarthome/", RightHomeAuto},
+	{"webservices/homeautoswitch.lua", RightHomeAuto},
+	{"api/v0/generic/", RightBoxAdmin},
+	{"data.lua", RightBoxAdmin},
+	{"query.lua", RightBoxAdmin},
+}
+
+// preflight checks the firmware and the rights for a request if checking is enabled.
+// write indicates whether the request modifies state on the box.
+func (c *Client) preflight(ctx context.Context, path string, write bool) error {
+	if err := c.checkFeature(ctx, path); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	skip := !c.checkRights || c.session == nil || c.session.sid == defaultSID
+	c.mu.RUnlock()
+	if skip {
+		return nil
+	}
+
+	path = strings.TrimPrefix(path, "/")
+	for _, pr := range pathRights {
+		if strings.HasPrefix(path, pr.prefix) {
+			access := AccessRead
+			if write {
+				access = AccessWrite
+			}
+			return c.Rights().Check(pr.right, access)
+		}
+	}
+	return nil
+}
+
+// isAhaWrite reports whether an AHA or data.lua request modifies state.
+func isAhaWrite(data Values) bool {
+	if _, ok := data["apply"]; ok {
+		return true
+	}
+	return strings.HasPrefix(data["switchcmd"], "set")
+}
+
+// isRestWrite reports whether a REST request modifies state.
+func isRestWrite(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead
+}
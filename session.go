@@ -2,13 +2,19 @@ package fritzbox
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"unicode/utf16"
 )
@@ -16,16 +22,40 @@ import (
 const (
 	defaultSID    = "0000000000000000"
 	sessionExpiry = 10 * time.Minute
+
+	// loginPath requests version 2 of the login protocol. Boxes running FRITZ!OS 7.24+
+	// answer with a PBKDF2 challenge, older ones ignore the parameter and send MD5.
+	loginPath = "login_sid.lua?version=2"
 )
 
 var ErrInvalidCredentials = errors.New("invalid credentials")
 
+// ErrLoginBlocked is returned when the FRITZ!Box refuses logins after failed attempts.
+// The returned error is a *LoginBlockedError carrying the remaining wait time.
+var ErrLoginBlocked = errors.New("login blocked")
+
+// LoginBlockedError reports how long the FRITZ!Box blocks further login attempts.
+type LoginBlockedError struct {
+	Wait time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("login blocked for %s", e.Wait)
+}
+
+// Is makes errors.Is(err, ErrLoginBlocked) match.
+func (e *LoginBlockedError) Is(target error) bool {
+	return target == ErrLoginBlocked
+}
+
 // session represents an authenticated FRITZ!Box session.
+// Its fields are guarded by client.mu.
 type session struct {
 	client *Client
 
 	sid       string
 	challenge string
+	lastUser  string
 	blockTime time.Duration
 	expires   time.Time
 
@@ -42,6 +72,14 @@ type sessionResponse struct {
 
 	RightsName   []string `xml:"Rights>Name"`
 	RightsAccess []int8   `xml:"Rights>Access"`
+
+	Users []sessionUser `xml:"Users>User"`
+}
+
+// sessionUser is an entry of the user list sent with the challenge.
+type sessionUser struct {
+	Name string `xml:",chardata"`
+	Last int    `xml:"last,attr"`
 }
 
 func newSession(c *Client) *session {
@@ -52,30 +90,51 @@ func newSession(c *Client) *session {
 }
 
 // open retrieves the challenge from FRITZ!Box.
-func (s *session) open() error {
+// Returns the time the box still blocks login attempts.
+func (s *session) open(ctx context.Context) (time.Duration, error) {
 	var resp sessionResponse
-	err := s.client.AhaRequestXML(http.MethodGet, "login_sid.lua", nil, &resp)
+	err := s.client.loginRequest(ctx, http.MethodGet, nil, &resp)
 	if err != nil {
-		return fmt.Errorf("get challenge: %w", err)
+		return 0, fmt.Errorf("get challenge: %w", err)
 	}
 
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
 	s.challenge = resp.Challenge
 	s.blockTime = time.Duration(resp.BlockTime) * time.Second
-	return nil
+	s.lastUser = ""
+	for _, u := range resp.Users {
+		if u.Last == 1 {
+			s.lastUser = u.Name
+		}
+	}
+	return s.blockTime, nil
 }
 
 // auth sends the challenge response and completes authentication.
-func (s *session) auth() error {
-	response, err := computeChallengeResponse(s.challenge, s.client.Password)
+func (s *session) auth(ctx context.Context) error {
+	username, password, err := s.client.loginCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.client.mu.Lock()
+	challenge := s.challenge
+	if username == "" && s.client.useLastUser && s.lastUser != "" {
+		username = s.lastUser
+	}
+	s.client.mu.Unlock()
+
+	response, err := computeChallengeResponse(challenge, password)
 	if err != nil {
 		return fmt.Errorf("compute response: %w", err)
 	}
 
 	var resp sessionResponse
-	err = s.client.AhaRequestXML(
+	err = s.client.loginRequest(
+		ctx,
 		http.MethodPost,
-		"login_sid.lua",
-		Values{"username": s.client.Username, "response": response},
+		Values{"username": username, "response": response},
 		&resp,
 	)
 	if err != nil {
@@ -83,9 +142,15 @@ func (s *session) auth() error {
 	}
 
 	if resp.SID == defaultSID {
+		if resp.BlockTime > 0 {
+			return fmt.Errorf("%w (next login possible in %ds)", ErrInvalidCredentials, resp.BlockTime)
+		}
 		return ErrInvalidCredentials
 	}
 
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.client.user = username
 	s.sid = resp.SID
 	s.expires = time.Now().Add(sessionExpiry)
 	s.rightsName = resp.RightsName
@@ -93,10 +158,47 @@ func (s *session) auth() error {
 	return nil
 }
 
+// resume checks whether sid is still valid and adopts it if so.
+func (s *session) resume(ctx context.Context, sid string) (bool, error) {
+	var resp sessionResponse
+	if err := s.client.loginRequest(ctx, http.MethodGet, Values{"sid": sid}, &resp); err != nil {
+		return false, fmt.Errorf("check session: %w", err)
+	}
+	if resp.SID != sid {
+		return false, nil
+	}
+
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.sid = resp.SID
+	s.expires = time.Now().Add(sessionExpiry)
+	s.rightsName = resp.RightsName
+	s.rightsAccess = resp.RightsAccess
+	return true, nil
+}
+
 func (s *session) close() {
 	s.sid = defaultSID
 }
 
+// rights converts the rights reported by login_sid.lua into a Rights map.
+func (s *session) rights() Rights {
+	r := make(Rights, len(s.rightsName))
+	for i, name := range s.rightsName {
+		if i < len(s.rightsAccess) {
+			r[Right(name)] = Access(s.rightsAccess[i])
+		}
+	}
+	return r
+}
+
+// touch extends the expiry after a successful request.
+func (s *session) touch() {
+	if s != nil && s.sid != defaultSID {
+		s.expires = time.Now().Add(sessionExpiry)
+	}
+}
+
 func (s *session) isExpired() bool {
 	return time.Now().After(s.expires)
 }
@@ -105,9 +207,70 @@ func (s *session) String() string {
 	return fmt.Sprintf("Session{sid: %s, expires: %s}", s.sid, s.expires.Format(time.RFC3339))
 }
 
-// computeChallengeResponse generates the MD5-based challenge response.
-// FRITZ!Box uses UTF-16LE encoding with codepoints >255 replaced by '.'.
+// computeChallengeResponse generates the response for the given challenge.
+// PBKDF2 challenges start with "2$", everything else is treated as legacy MD5.
 func computeChallengeResponse(challenge, password string) (string, error) {
+	if strings.HasPrefix(challenge, "2$") {
+		return computePBKDF2Response(challenge, password)
+	}
+	return computeMD5Response(challenge, password)
+}
+
+// computePBKDF2Response generates the PBKDF2-SHA256 challenge response.
+// The challenge has the format 2$<iter1>$<salt1>$<iter2>$<salt2>; the response
+// is <salt2>$<hex(pbkdf2(pbkdf2(password, salt1, iter1), salt2, iter2))>.
+func computePBKDF2Response(challenge, password string) (string, error) {
+	parts := strings.Split(challenge, "$")
+	if len(parts) != 5 {
+		return "", fmt.Errorf("invalid pbkdf2 challenge %q", challenge)
+	}
+
+	iter1, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("parse iter1: %w", err)
+	}
+	salt1, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("parse salt1: %w", err)
+	}
+	iter2, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", fmt.Errorf("parse iter2: %w", err)
+	}
+	salt2, err := hex.DecodeString(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("parse salt2: %w", err)
+	}
+
+	hash1 := pbkdf2SHA256([]byte(password), salt1, iter1)
+	hash2 := pbkdf2SHA256(hash1, salt2, iter2)
+	return fmt.Sprintf("%s$%x", parts[4], hash2), nil
+}
+
+// pbkdf2SHA256 derives a single SHA-256 sized block as described in RFC 8018.
+// The FRITZ!Box always uses a key length equal to the hash size, so one block suffices.
+func pbkdf2SHA256(password, salt []byte, iter int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iter; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// computeMD5Response generates the legacy MD5-based challenge response.
+// FRITZ!Box uses UTF-16LE encoding with codepoints >255 replaced by '.'.
+func computeMD5Response(challenge, password string) (string, error) {
 	buf := new(bytes.Buffer)
 	h := md5.New()
 
@@ -0,0 +1,189 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StoredSession is a session persisted by a SessionStore.
+type StoredSession struct {
+	BaseURL  string `json:"base_url"`
+	Username string `json:"username"`
+	SID      string `json:"sid"`
+}
+
+// SessionStore persists the session ID between processes.
+// Connect restores a stored session if it is still valid on the box and only logs in
+// again otherwise. New session IDs are saved after each login; Close clears the store.
+type SessionStore interface {
+	// Load returns the stored session, or nil if there is none.
+	Load() (*StoredSession, error)
+	// Save replaces the stored session.
+	Save(s *StoredSession) error
+	// Clear removes the stored session.
+	Clear() error
+}
+
+// FileSessionStore stores the session as JSON in a file only readable by the current user.
+type FileSessionStore struct {
+	Path string
+}
+
+// NewFileSessionStore returns a store that keeps the session in the file at path.
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{Path: path}
+}
+
+// Load reads the session from the file. A missing file is not an error.
+func (f *FileSessionStore) Load() (*StoredSession, error) {
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s StoredSession
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse session file: %w", err)
+	}
+	return &s, nil
+}
+
+// Save writes the session to the file with 0600 permissions.
+// The file is replaced atomically, so concurrent processes never read a partial file.
+func (f *FileSessionStore) Save(s *StoredSession) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, b)
+}
+
+// writeFileAtomic replaces the file at path with b and 0600 permissions.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Clear removes the file. A missing file is not an error.
+func (f *FileSessionStore) Clear() error {
+	err := os.Remove(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SetSessionStore sets the store used to persist the session. Must be called before Connect().
+func (c *Client) SetSessionStore(store SessionStore) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// resume restores the stored session if it belongs to this box and user and
+// is still accepted by the box. Any failure falls back to a regular login.
+// A session the box rejects is removed from the store.
+func (c *Client) resume(ctx context.Context) bool {
+	c.mu.Lock()
+	store := c.store
+	if store == nil {
+		c.mu.Unlock()
+		return false
+	}
+	if c.session == nil {
+		c.session = newSession(c)
+	}
+	s := c.session
+	baseURL := c.urlLocked()
+	c.mu.Unlock()
+
+	username, _, err := c.loginCredentials(ctx)
+	if err != nil {
+		return false
+	}
+
+	stored, err := store.Load()
+	if err != nil || stored == nil || stored.SID == "" || stored.SID == defaultSID {
+		return false
+	}
+	// with SetUseLastUser and no username, the stored user is the one picked last time
+	lastUser := username == "" && c.usesLastUser()
+	if stored.BaseURL != baseURL || (stored.Username != username && !lastUser) {
+		return false
+	}
+
+	ok, err := s.resume(ctx, stored.SID)
+	if err != nil {
+		return false
+	}
+	if !ok {
+		// the box no longer knows the session; don't offer it again if the login fails
+		if err := c.clearSession(); err != nil {
+			c.Logger().Warn("clear stale session", "error", err)
+		}
+		return false
+	}
+	c.mu.Lock()
+	c.user = stored.Username
+	c.mu.Unlock()
+	return true
+}
+
+// saveSession writes the current session ID to the store, if one is set.
+func (c *Client) saveSession() error {
+	c.mu.RLock()
+	store := c.store
+	stored := &StoredSession{BaseURL: c.urlLocked(), Username: c.user}
+	if c.session != nil {
+		stored.SID = c.session.sid
+	}
+	c.mu.RUnlock()
+
+	if store == nil || stored.SID == defaultSID {
+		return nil
+	}
+	if err := store.Save(stored); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// clearSession removes the session from the store, if one is set.
+func (c *Client) clearSession() error {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+
+	if store == nil {
+		return nil
+	}
+	if err := store.Clear(); err != nil {
+		return fmt.Errorf("clear session: %w", err)
+	}
+	return nil
+}
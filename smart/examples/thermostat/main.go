@@ -16,12 +16,18 @@ func main() {
 	uid := flag.String("uid", "", "Thermostat UID (optional, shows details)")
 	flag.Parse()
 
-	if *user == "" || *pass == "" {
+	cfg := fritzbox.Config{Username: *user, Password: *pass}
+	if *pass == "" && os.Getenv("FRITZBOX_PASSWORD") == "" {
 		fmt.Fprintln(os.Stderr, "Usage: thermostat -user=<username> -pass=<password> [-uid=<uid>]")
+		fmt.Fprintln(os.Stderr, "       or set FRITZBOX_USERNAME and FRITZBOX_PASSWORD")
 		os.Exit(1)
 	}
 
-	client := fritzbox.New(*user, *pass)
+	client, err := fritzbox.NewFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+		os.Exit(1)
+	}
 	if err := client.Connect(); err != nil {
 		fmt.Fprintf(os.Stderr, "Connection failed: %v\n", err)
 		os.Exit(1)
@@ -32,6 +38,10 @@ func main() {
 	} else {
 		showDetails(client, *uid)
 	}
+
+	if err := client.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Logout failed: %v\n", err)
+	}
 }
 
 func listThermostats(client *fritzbox.Client) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
 	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
 )
 
@@ -78,6 +79,13 @@ func intPtrToTime(minutes *int) time.Time {
 	return TimeFromYearMinutes(time.Now().UTC().Year(), int64(*minutes))
 }
 
+// put sends data to the overview endpoint. The setters below send absolute states,
+// so a retry policy may repeat them after a connection failure.
+func (h *ThermostatHandle) put(data *rest.EndpointOverviewPutUnit) error {
+	c := h.client.WithContext(fritzbox.WithIdempotent(h.client.Context()))
+	return rest.PutOverviewUnit(c, h.uid, data)
+}
+
 // SetTargetTemperature sets the current target temperature (8-28°C).
 func (h *ThermostatHandle) SetTargetTemperature(celsius float64) error {
 	cel := float32(celsius)
@@ -91,7 +99,7 @@ func (h *ThermostatHandle) SetTargetTemperature(celsius float64) error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // TurnOff turns off heating for this thermostat.
@@ -105,7 +113,7 @@ func (h *ThermostatHandle) TurnOff() error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // TurnOn turns heating to maximum for this thermostat.
@@ -119,7 +127,7 @@ func (h *ThermostatHandle) TurnOn() error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // SetBoost activates boost mode for the given duration in minutes.
@@ -136,7 +144,7 @@ func (h *ThermostatHandle) SetBoost(minutes int) error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // DeactivateBoost deactivates boost mode.
@@ -151,7 +159,7 @@ func (h *ThermostatHandle) DeactivateBoost() error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // SetWindowOpen activates window-open mode for the given duration in minutes.
@@ -168,7 +176,7 @@ func (h *ThermostatHandle) SetWindowOpen(minutes int) error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // DeactivateWindowOpen deactivates window-open mode.
@@ -183,7 +191,7 @@ func (h *ThermostatHandle) DeactivateWindowOpen() error {
 			},
 		},
 	}
-	return rest.PutOverviewUnit(h.client, h.uid, data)
+	return h.put(data)
 }
 
 // SetComfortPreset sets the "comfort" preset temperature value used by the weekly timer.
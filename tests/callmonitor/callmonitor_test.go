@@ -0,0 +1,88 @@
+package callmonitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/callmonitor"
+)
+
+func TestParse(t *testing.T) {
+	at := time.Date(2026, 10, 16, 18, 42, 10, 0, time.Local)
+	for line, want := range map[string]callmonitor.Event{
+		"16.10.26 18:42:10;RING;0;0301234567;987654;SIP0;": {
+			Time: at, Type: callmonitor.Ring, Caller: "0301234567", Called: "987654", Line: "SIP0",
+		},
+		"16.10.26 18:42:10;CALL;1;10;987654;0401111;SIP1;": {
+			Time: at, Type: callmonitor.Call, ConnectionID: 1, Extension: "10", Caller: "987654", Called: "0401111", Line: "SIP1",
+		},
+		"16.10.26 18:42:10;CONNECT;0;10;0301234567;": {
+			Time: at, Type: callmonitor.Connect, Extension: "10", Number: "0301234567",
+		},
+		"16.10.26 18:42:10;DISCONNECT;0;75;\r\n": {
+			Time: at, Type: callmonitor.Disconnect, Duration: 75 * time.Second,
+		},
+	} {
+		got, err := callmonitor.Parse(line)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %+v, %v", line, got, err)
+		}
+	}
+
+	for _, line := range []string{"", "16.10.26 18:42:10;HOLD;0;", "yesterday;RING;0;1;2;SIP0;", "16.10.26 18:42:10;DISCONNECT;0;long;"} {
+		if _, err := callmonitor.Parse(line); err == nil {
+			t.Errorf("Parse(%q) succeeded", line)
+		}
+	}
+}
+
+func TestNewAddr(t *testing.T) {
+	c := fritzbox.New("u", "p")
+	c.BaseUrl = "https://fritz.box/"
+	if got := callmonitor.New(c).Addr; got != "fritz.box:1012" {
+		t.Errorf("Addr = %q", got)
+	}
+}
+
+func TestEventsReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the box sends one event per connection and drops it
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(conn, "16.10.26 18:42:1%d;RING;%d;030123;987654;SIP0;\r\n", i, i)
+			fmt.Fprint(conn, "garbage\r\n")
+			conn.Close()
+		}
+	}()
+
+	m := callmonitor.New(fritzbox.New("", ""))
+	m.Addr = ln.Addr().String()
+	m.MinBackoff = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := m.Events(ctx)
+	for i := 0; i < 3; i++ {
+		ev := <-events
+		if ev.Type != callmonitor.Ring || ev.ConnectionID != i {
+			t.Fatalf("event %d = %+v", i, ev)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
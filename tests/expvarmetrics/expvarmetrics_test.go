@@ -0,0 +1,67 @@
+package expvarmetrics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/expvarmetrics"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+
+	client := srv.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	m := expvarmetrics.NewUnpublished()
+	client.SetMetrics(m)
+
+	for i := 0; i < 3; i++ {
+		if _, err := rest.GetOverviewUnitByUID(client, uid); err != nil {
+			t.Fatalf("get unit: %v", err)
+		}
+	}
+	_, _ = rest.GetOverviewUnitByUID(client, "unknown")
+	srv.ExpireSessions()
+	if _, err := rest.GetOverviewUnitByUID(client, uid); err != nil {
+		t.Fatalf("get unit after expiry: %v", err)
+	}
+
+	var vars struct {
+		Requests map[string]int64 `json:"requests"`
+		Latency  map[string]struct {
+			Count   int64            `json:"count"`
+			SumMs   float64          `json:"sum_ms"`
+			Buckets map[string]int64 `json:"buckets"`
+		} `json:"latency"`
+		Status          map[string]int64 `json:"status"`
+		RestCodes       map[string]int64 `json:"rest_codes"`
+		Relogins        int64            `json:"relogins"`
+		ReloginFailures int64            `json:"relogin_failures"`
+		BytesReceived   int64            `json:"bytes_received"`
+	}
+	if err := json.Unmarshal([]byte(m.Vars().String()), &vars); err != nil {
+		t.Fatalf("parse vars: %v\n%s", err, m.Vars())
+	}
+
+	const key = "GET api/v0/smarthome/overview/units/{id}"
+	if vars.Requests[key] != 5 {
+		t.Errorf("requests = %v", vars.Requests)
+	}
+	if h := vars.Latency[key]; h.Count != 5 || h.Buckets["+Inf"] != 5 {
+		t.Errorf("latency = %+v", h)
+	}
+	if vars.Status["404"] != 1 || vars.RestCodes["2001"] != 1 {
+		t.Errorf("status = %v, rest codes = %v", vars.Status, vars.RestCodes)
+	}
+	if vars.Relogins != 1 || vars.ReloginFailures != 0 || vars.BytesReceived == 0 {
+		t.Errorf("vars = %+v", vars)
+	}
+}
@@ -0,0 +1,93 @@
+package fritzbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func TestBoxInfo(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.Update(func(m *fritztest.Model) {
+		m.Box.Firmware = "154.08.20-117321"
+		m.Box.Lab = true
+		m.Box.Flags = []string{"mesh_master", "medium_data"}
+	})
+
+	// no login needed
+	client := srv.NewClient()
+	info, err := client.BoxInfo()
+	if err != nil {
+		t.Fatalf("box info: %v", err)
+	}
+	if info.Model != "FRITZ!Box 7590" || info.Version != (fritzbox.Version{Major: 8, Minor: 20}) ||
+		info.Firmware != "154.08.20-117321" || !info.Lab || !info.HasFlag("mesh_master") || info.Language != "de" {
+		t.Errorf("info = %+v", info)
+	}
+	// reading it leaves the client unconnected
+	if _, _, err := client.RestGet("api/v0/smarthome/overview"); !errors.Is(err, fritzbox.ErrNotConnected) {
+		t.Errorf("request after box info: err = %v, want ErrNotConnected", err)
+	}
+
+	for _, tc := range []struct {
+		feature fritzbox.Feature
+		want    bool
+	}{
+		{fritzbox.FeatureAHA, true},
+		{fritzbox.FeatureSmartHomeREST, true},
+	} {
+		if ok, err := client.Supports(tc.feature); ok != tc.want || err != nil {
+			t.Errorf("supports %s = %v, %v", tc.feature, ok, err)
+		}
+	}
+}
+
+func TestFeatureCheck(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.Update(func(m *fritztest.Model) {
+		m.Box.Version = fritzbox.Version{Major: 7, Minor: 57}
+	})
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	client := newServerClient(t, srv)
+
+	if ok, err := client.Supports(fritzbox.FeatureSmartHomeREST); ok || err != nil {
+		t.Errorf("supports REST on 7.57 = %v, %v", ok, err)
+	}
+
+	client.SetFeatureCheck(true)
+	_, err := smart.GetAllThermostats(client)
+	if !errors.Is(err, fritzbox.ErrNotSupported) {
+		t.Fatalf("REST on 7.57 = %v", err)
+	}
+	if want := "not supported by this FRITZ!OS version: smart home REST API needs FRITZ!OS 8.20, FRITZ!Box 7590 runs 7.57"; err.Error() != want {
+		t.Errorf("error = %q", err)
+	}
+
+	// AHA still works
+	if _, _, err := client.AhaRequestString("GET", "webservices/homeautoswitch.lua", fritzbox.Values{
+		"sid": client.SID(), "switchcmd": "getswitchname", "ain": "09995 0000001",
+	}); err != nil {
+		t.Errorf("AHA on 7.57: %v", err)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for in, want := range map[string]fritzbox.Version{
+		"154.07.57":        {Major: 7, Minor: 57},
+		"07.29":            {Major: 7, Minor: 29},
+		"8.2":              {Major: 8, Minor: 2},
+		"171.08.21-117321": {Major: 8, Minor: 21},
+	} {
+		if got, err := fritzbox.ParseVersion(in); got != want || err != nil {
+			t.Errorf("ParseVersion(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := fritzbox.ParseVersion("8"); err == nil {
+		t.Error("ParseVersion(8) succeeded")
+	}
+}
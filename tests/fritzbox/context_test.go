@@ -0,0 +1,139 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+// hangingBox serves srv, but requests matching hang block until the client gives up
+// or the test ends.
+func hangingBox(t *testing.T, srv *fritztest.Server, hang func(*http.Request) bool) *fritzbox.Client {
+	t.Helper()
+	done := make(chan struct{})
+	box := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang(r) {
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+			return
+		}
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(box.Close)
+	t.Cleanup(func() { close(done) })
+
+	client := fritzbox.New("user", "secret")
+	client.BaseUrl = box.URL + "/"
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// TestContextAbortsRequest checks that a deadline set with WithContext ends a request
+// the box does not answer, and that the limiter slot is freed.
+func TestContextAbortsRequest(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+
+	var hanging atomic.Bool
+	client := hangingBox(t, srv, func(r *http.Request) bool {
+		return hanging.Load() && !strings.HasSuffix(r.URL.Path, "/login_sid.lua")
+	})
+	client.SetLimits(fritzbox.Limits{MaxInFlight: 1})
+	hanging.Store(true)
+
+	for name, request := range map[string]func(*fritzbox.Client) error{
+		"rest": func(c *fritzbox.Client) error {
+			_, _, err := c.RestGet("api/v0/smarthome/overview")
+			return err
+		},
+		"aha": func(c *fritzbox.Client) error {
+			resp, err := c.AhaRequest(http.MethodGet, "webservices/homeautoswitch.lua",
+				fritzbox.Values{"switchcmd": "getdevicelistinfos", "sid": c.SID()})
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err
+		},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		err := request(client.WithContext(ctx))
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: err = %v, want context.DeadlineExceeded", name, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%s: returned after %s", name, d)
+		}
+	}
+
+	// with a leaked slot, this request would wait for its deadline
+	hanging.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, _, err := client.WithContext(ctx).RestGet("api/v0/smarthome/overview"); err != nil {
+		t.Errorf("request after timeouts: %v", err)
+	}
+	if n := client.QueuedRequests(); n != 0 {
+		t.Errorf("%d requests queued", n)
+	}
+}
+
+// TestContextAbortsLoginWait checks that callers waiting for a re-login the box does
+// not answer return at their deadline, and that a later request logs in normally.
+func TestContextAbortsLoginWait(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+
+	var hanging atomic.Bool
+	client := hangingBox(t, srv, func(r *http.Request) bool {
+		return hanging.Load() && isLogin(r)
+	})
+	hanging.Store(true)
+	srv.ExpireSessions()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, _, err := client.WithContext(ctx).RestGet("api/v0/smarthome/overview")
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want context.DeadlineExceeded", err)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("returned after %s", d)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Close detaches the login still in flight, so Connect does not wait for it
+	hanging.Store(false)
+	client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, status, err := client.RestGet("api/v0/smarthome/overview"); err != nil || status != http.StatusOK {
+		t.Errorf("request after login = %d, %v", status, err)
+	}
+}
@@ -0,0 +1,259 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvCredentials(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("FRITZBOX_USERNAME", "user")
+	t.Setenv("FRITZBOX_PASSWORD", "secret")
+	if u, p, err := (fritzbox.EnvCredentials{}).Credentials(ctx); u != "user" || p != "secret" || err != nil {
+		t.Errorf("default vars = %q, %q, %v", u, p, err)
+	}
+
+	t.Setenv("BOX_PASS", "other")
+	e := fritzbox.EnvCredentials{UsernameVar: "BOX_USER", PasswordVar: "BOX_PASS"}
+	if u, p, err := e.Credentials(ctx); u != "" || p != "other" || err != nil {
+		t.Errorf("custom vars = %q, %q, %v", u, p, err)
+	}
+
+	e.PasswordVar = "BOX_MISSING"
+	if _, _, err := e.Credentials(ctx); !errors.Is(err, fritzbox.ErrNoCredentials) {
+		t.Errorf("missing password: err = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	ctx := context.Background()
+	passFile := writeFile(t, "password", "secret\n")
+	userFile := writeFile(t, "username", " admin \n")
+
+	f := fritzbox.FileCredentials{Username: "user", PasswordFile: passFile}
+	if u, p, err := f.Credentials(ctx); u != "user" || p != "secret" || err != nil {
+		t.Errorf("password file = %q, %q, %v", u, p, err)
+	}
+	f.UsernameFile = userFile
+	if u, p, err := f.Credentials(ctx); u != "admin" || p != "secret" || err != nil {
+		t.Errorf("username file = %q, %q, %v", u, p, err)
+	}
+	f.PasswordFile = filepath.Join(t.TempDir(), "missing")
+	if _, _, err := f.Credentials(ctx); err == nil {
+		t.Error("missing password file: no error")
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		netrc   string
+		machine string
+		user    string
+		pass    string
+		missing bool
+	}{
+		{
+			name:  "default machine",
+			netrc: "machine example.com login other password x\nmachine fritz.box login user password secret\n",
+			user:  "user", pass: "secret",
+		},
+		{
+			name:    "one line per token",
+			netrc:   "machine\n192.168.178.1\nlogin\nuser\npassword\nsecret\n",
+			machine: "192.168.178.1",
+			user:    "user", pass: "secret",
+		},
+		{
+			name:  "comments and account",
+			netrc: "# box\nmachine fritz.box account x login user password secret\n",
+			user:  "user", pass: "secret",
+		},
+		{
+			name:    "default entry",
+			netrc:   "machine example.com login other password x\ndefault login user password secret\n",
+			machine: "box.lan",
+			user:    "user", pass: "secret",
+		},
+		{
+			name:    "machine before default",
+			netrc:   "default login other password x\nmachine box.lan login user password secret\n",
+			machine: "box.lan",
+			user:    "user", pass: "secret",
+		},
+		{
+			name:  "macdef is skipped",
+			netrc: "machine fritz.box login user password secret\nmacdef init\ncd /\n\nmachine example.com login other password x\n",
+			user:  "user", pass: "secret",
+		},
+		{
+			name:  "entries after macdef",
+			netrc: "macdef init\ncd /\nmachine fritz.box login other password x\n\nmachine fritz.box login user password secret\n",
+			user:  "user", pass: "secret",
+		},
+		{
+			name:    "missing machine",
+			netrc:   "machine example.com login other password x\n",
+			missing: true,
+		},
+		{
+			name:    "login outside an entry",
+			netrc:   "login user password secret\n",
+			missing: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n := fritzbox.NetrcCredentials{Path: writeFile(t, "netrc", tc.netrc), Machine: tc.machine}
+			u, p, err := n.Credentials(context.Background())
+			if tc.missing {
+				if !errors.Is(err, fritzbox.ErrNoCredentials) {
+					t.Errorf("err = %v, want ErrNoCredentials", err)
+				}
+				return
+			}
+			if u != tc.user || p != tc.pass || err != nil {
+				t.Errorf("got %q, %q, %v; want %q, %q", u, p, err, tc.user, tc.pass)
+			}
+		})
+	}
+
+	n := fritzbox.NetrcCredentials{Path: filepath.Join(t.TempDir(), "missing")}
+	if _, _, err := n.Credentials(context.Background()); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestCredentialProvider(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+
+	client := fritzbox.New("", "")
+	client.BaseUrl = srv.URL + "/"
+	client.SetCredentialProvider(fritzbox.StaticCredentials{Username: "user", Password: "secret"})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if client.LoginUser() != "user" || client.Username != "" {
+		t.Errorf("login user = %q, Username = %q", client.LoginUser(), client.Username)
+	}
+	if u, p, err := client.Credentials(context.Background()); u != "user" || p != "secret" || err != nil {
+		t.Errorf("Credentials() = %q, %q, %v", u, p, err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		`"10s"`:   10 * time.Second,
+		`"1m30s"`: 90 * time.Second,
+		`5`:       5 * time.Second,
+		`0.5`:     500 * time.Millisecond,
+		`null`:    0,
+	} {
+		var d fritzbox.Duration
+		if err := json.Unmarshal([]byte(in), &d); err != nil || time.Duration(d) != want {
+			t.Errorf("unmarshal %s = %v, %v; want %v", in, time.Duration(d), err, want)
+		}
+	}
+	for _, in := range []string{`"soon"`, `true`} {
+		var d fritzbox.Duration
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("unmarshal %s: no error", in)
+		}
+	}
+
+	b, err := json.Marshal(fritzbox.Duration(90 * time.Second))
+	if err != nil || string(b) != `"1m30s"` {
+		t.Errorf("marshal = %s, %v", b, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+
+	path := writeFile(t, "config.json", `{
+		"base_url": "`+srv.URL+`/",
+		"username": "user",
+		"password_file": "`+writeFile(t, "password", "secret\n")+`",
+		"timeout": 5
+	}`)
+	cfg, err := fritzbox.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Username != "user" || time.Duration(cfg.Timeout) != 5*time.Second {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	client, err := fritzbox.NewFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if client.HTTPClient().Timeout != 5*time.Second {
+		t.Errorf("timeout = %v", client.HTTPClient().Timeout)
+	}
+
+	if cfg, err := fritzbox.LoadConfig(writeFile(t, "null.json", `{"timeout": null}`)); err != nil || cfg.Timeout != 0 {
+		t.Errorf("null timeout = %v, %v", time.Duration(cfg.Timeout), err)
+	}
+	if _, err := fritzbox.LoadConfig(writeFile(t, "bad.json", `{"timeout": "soon"}`)); err == nil {
+		t.Error("invalid config: no error")
+	}
+	if _, err := fritzbox.LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing config: no error")
+	}
+}
+
+func TestNewFromConfigCredentials(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	base := srv.URL + "/"
+	t.Setenv("FRITZBOX_USERNAME", "")
+	t.Setenv("FRITZBOX_PASSWORD", "secret")
+
+	for name, cfg := range map[string]fritzbox.Config{
+		"password":    {BaseUrl: base, Username: "user", Password: "secret"},
+		"provider":    {BaseUrl: base, Password: "wrong", Credentials: fritzbox.StaticCredentials{Username: "user", Password: "secret"}},
+		"netrc":       {BaseUrl: base, Netrc: writeFile(t, "netrc", "machine 127.0.0.1 login user password secret\n")},
+		"environment": {BaseUrl: base, Username: "user"},
+	} {
+		client, err := fritzbox.NewFromConfig(cfg)
+		if err != nil {
+			t.Errorf("%s: new: %v", name, err)
+			continue
+		}
+		if err := client.Connect(); err != nil {
+			t.Errorf("%s: connect: %v", name, err)
+			continue
+		}
+		client.Close()
+	}
+
+	if _, err := fritzbox.NewFromConfig(fritzbox.Config{TLS: fritzbox.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}}); err == nil {
+		t.Error("missing ca file: no error")
+	}
+}
@@ -0,0 +1,147 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+// ssdpResponder answers M-SEARCH requests for the TR-064 device once for each
+// location, in order.
+func ssdpResponder(t *testing.T, locations ...string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := string(buf[:n])
+			if !strings.HasPrefix(req, "M-SEARCH") || !strings.Contains(req, "urn:dslforum-org:device:InternetGatewayDevice:1") {
+				continue
+			}
+			for _, location := range locations {
+				resp := "HTTP/1.1 200 OK\r\n" +
+					"CACHE-CONTROL: max-age=1800\r\n" +
+					"LOCATION: " + location + "\r\n" +
+					"SERVER: FRITZ!Box 7590 UPnP/1.0 AVM FRITZ!Box 7590 154.08.20\r\n" +
+					"ST: urn:dslforum-org:device:InternetGatewayDevice:1\r\n" +
+					"USN: uuid:75802409-bccb-40e7-8e6c-3810d5a1b2c3::urn:dslforum-org:device:InternetGatewayDevice:1\r\n\r\n"
+				// answer twice, as boxes do for each interface
+				conn.WriteTo([]byte(resp), addr)
+				conn.WriteTo([]byte(resp), addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDiscover(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.Update(func(m *fritztest.Model) {
+		m.Box.Firmware = "154.08.20"
+	})
+
+	location := srv.URL + "/tr64desc.xml"
+	d := fritzbox.Discoverer{
+		Addr:         ssdpResponder(t, location),
+		Wait:         200 * time.Millisecond,
+		FallbackHost: "-",
+	}
+	boxes, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(boxes) != 1 {
+		t.Fatalf("found %d boxes, want 1", len(boxes))
+	}
+
+	box := boxes[0]
+	if box.BaseURL != "http://127.0.0.1/" || box.Location != location || box.Model != "FRITZ!Box 7590" ||
+		box.Firmware != "154.08.20" || box.Version != (fritzbox.Version{Major: 8, Minor: 20}) ||
+		box.UDN != "uuid:75802409-bccb-40e7-8e6c-3810d5a1b2c3" || !box.Router {
+		t.Errorf("box = %+v", box)
+	}
+}
+
+func TestDiscoverNone(t *testing.T) {
+	// nobody answers on this address
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	d := fritzbox.Discoverer{
+		Addr:         conn.LocalAddr().String(),
+		Wait:         50 * time.Millisecond,
+		FallbackHost: "-",
+	}
+	if _, err := d.Discover(context.Background()); !errors.Is(err, fritzbox.ErrNoBoxFound) {
+		t.Errorf("err = %v, want ErrNoBoxFound", err)
+	}
+
+	// the context ends the search early
+	d.Wait = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := d.Discover(ctx); err == nil {
+		t.Error("discover succeeded without an answer")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("discover ignored the context deadline")
+	}
+}
+
+// TestFindRouter checks that a repeater answering first is skipped.
+func TestFindRouter(t *testing.T) {
+	router := fritztest.NewServer("user", "secret")
+	defer router.Close()
+	repeater := fritztest.NewServer("user", "secret")
+	defer repeater.Close()
+	repeater.Update(func(m *fritztest.Model) {
+		m.Box.Model = "FRITZ!Repeater 6000"
+		m.UDN = "uuid:75802409-bccb-40e7-8e6c-000000000001"
+		m.Repeater = true
+	})
+
+	d := fritzbox.Discoverer{
+		Addr:         ssdpResponder(t, repeater.URL+"/tr64desc.xml", router.URL+"/tr64desc.xml"),
+		Wait:         200 * time.Millisecond,
+		FallbackHost: "-",
+	}
+	boxes, err := d.Discover(context.Background())
+	if err != nil || len(boxes) != 2 || boxes[0].Router || !boxes[1].Router {
+		t.Fatalf("discover = %+v, %v", boxes, err)
+	}
+	box, err := d.FindRouter(context.Background())
+	if err != nil || box.Location != router.URL+"/tr64desc.xml" {
+		t.Errorf("router = %+v, %v", box, err)
+	}
+
+	// the fallback host names the router without waiting for the search
+	d.FallbackHost = "localhost"
+	d.Wait = time.Minute
+	start := time.Now()
+	if box, err := d.FindRouter(context.Background()); err != nil || !box.Router {
+		t.Errorf("router via fallback host = %+v, %v", box, err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("fallback host did not skip the search")
+	}
+}
@@ -0,0 +1,38 @@
+package fritzbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/aha"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+// TestForbidden checks that a 403 for missing rights is returned without
+// logging in again.
+func TestForbidden(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	srv.SetRights(fritzbox.Rights{fritzbox.RightBoxAdmin: fritzbox.AccessWrite})
+	client := newServerClient(t, srv)
+	sid := client.SID()
+
+	body, status, err := client.RestGet("api/v0/smarthome/overview")
+	if err != nil || status != 403 || !strings.Contains(string(body), "3001") {
+		t.Fatalf("REST = %d %s, %v; want 403 with code 3001", status, body, err)
+	}
+	if client.SID() != sid || srv.Sessions() != 1 {
+		t.Errorf("REST 403 logged in again")
+	}
+
+	if _, err := aha.GetDeviceList(client); !errors.Is(err, fritzbox.ErrInsufficientRights) {
+		t.Fatalf("AHA err = %v, want ErrInsufficientRights", err)
+	}
+	if client.SID() != sid || srv.Sessions() != 1 {
+		t.Errorf("AHA 403 logged in again")
+	}
+}
@@ -0,0 +1,39 @@
+package fritzbox
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func newServerClient(t *testing.T, srv *fritztest.Server) *fritzbox.Client {
+	t.Helper()
+	client := srv.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// isLogin reports whether req posts to login_sid.lua, which sends a challenge response
+// or, on Close, logs out.
+func isLogin(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/login_sid.lua") && req.Method == http.MethodPost
+}
+
+// waitQueued waits until n requests wait for the limiter of client.
+func waitQueued(t *testing.T, client *fritzbox.Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.QueuedRequests() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", client.QueuedRequests(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,66 @@
+package fritzbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func TestSetLogger(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+
+	var buf bytes.Buffer
+	client := srv.NewClient()
+	client.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	srv.ExpireSessions()
+	if _, err := smart.GetThermostat(client, uid); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	type record struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Path   string `json:"path"`
+		Status int    `json:"status"`
+	}
+	var records []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode log: %v", err)
+		}
+		records = append(records, r)
+	}
+
+	var rejected, relogin, ok bool
+	for _, r := range records {
+		switch {
+		case r.Msg == "request" && r.Level == "DEBUG" && r.Path == "/api/v0/smarthome/overview":
+			rejected = rejected || r.Status == 401
+			ok = ok || r.Status == 200
+		case r.Msg == "session rejected, logged in again" && r.Level == "INFO":
+			relogin = true
+		}
+	}
+	if !rejected || !relogin || !ok {
+		t.Errorf("records = %+v; want the rejected request, the re-login and the replay", records)
+	}
+
+	client.SetLogger(nil)
+	if l := client.Logger(); l == nil || l.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("Logger() after SetLogger(nil) = %v, want a discarding logger", l)
+	}
+}
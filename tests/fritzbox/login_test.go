@@ -0,0 +1,193 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+// TestConcurrentRelogin checks that requests hitting an expired session at the same
+// time share a single login.
+func TestConcurrentRelogin(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	client := newServerClient(t, srv)
+
+	var mu sync.Mutex
+	logins := 0
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if isLogin(req) {
+			mu.Lock()
+			logins++
+			mu.Unlock()
+		}
+	}, nil))
+
+	srv.ExpireSessions()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := smart.GetThermostat(client, uid); err != nil {
+				t.Errorf("request after expiry: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if logins != 1 {
+		t.Errorf("%d logins, want 1", logins)
+	}
+	if n := srv.Sessions(); n != 1 {
+		t.Errorf("%d sessions, want 1", n)
+	}
+}
+
+// TestReloginOutlivesCaller checks that the caller starting a shared login can give up
+// without failing the login for the others.
+func TestReloginOutlivesCaller(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := newServerClient(t, srv)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if isLogin(req) {
+			once.Do(func() {
+				close(started)
+				<-release
+			})
+		}
+	}, nil))
+
+	srv.ExpireSessions()
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, _, err := client.RestRequestContext(ctx, http.MethodGet, "api/v0/smarthome/overview", nil)
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, status, err := client.RestGet("api/v0/smarthome/overview")
+		if err == nil && status != http.StatusOK {
+			err = errors.New(http.StatusText(status))
+		}
+		waiter <- err
+	}()
+
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader: err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("waiter: %v", err)
+	}
+	if n := srv.Sessions(); n != 1 {
+		t.Errorf("%d sessions, want 1", n)
+	}
+}
+
+// TestConnectDuringRelogin checks that Connect after Close does not join a re-login
+// still running for the closed session.
+func TestConnectDuringRelogin(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := newServerClient(t, srv)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	// only the first login blocks; the logout and the login of Connect pass
+	var blocked atomic.Bool
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if isLogin(req) && blocked.CompareAndSwap(false, true) {
+			close(started)
+			<-release
+		}
+	}, nil))
+
+	srv.ExpireSessions()
+	go func() { _, _, _ = client.RestGet("api/v0/smarthome/overview") }()
+	<-started
+
+	client.Close()
+	connected := make(chan error)
+	go func() { connected <- client.Connect() }()
+	select {
+	case err := <-connected:
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connect waits for the re-login of the closed session")
+	}
+	if !client.IsConnected() || client.SID() == "" {
+		t.Errorf("connected = %v, SID = %q", client.IsConnected(), client.SID())
+	}
+}
+
+func TestLoginBlocked(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.SetBlockTime(30)
+
+	client := srv.NewClient()
+	var posts atomic.Int32
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if isLogin(req) {
+			posts.Add(1)
+		}
+	}, nil))
+	client.SetLoginBlockWait(time.Second)
+
+	err := client.Connect()
+	var blocked *fritzbox.LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.Wait != 30*time.Second {
+		t.Fatalf("err = %v, want LoginBlockedError of 30s", err)
+	}
+	if !errors.Is(err, fritzbox.ErrLoginBlocked) {
+		t.Errorf("errors.Is(%v, ErrLoginBlocked) = false", err)
+	}
+	if n := posts.Load(); n != 0 {
+		t.Errorf("%d login attempts while blocked", n)
+	}
+}
+
+func TestLoginBlockWait(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.SetBlockTime(1)
+
+	client := srv.NewClient()
+	// the block is over once the box has reported it
+	client.Use(fritzbox.Hooks(nil, func(req *http.Request, _ *http.Response, _ error) {
+		if strings.HasSuffix(req.URL.Path, "/login_sid.lua") && req.Method == http.MethodGet {
+			srv.SetBlockTime(0)
+		}
+	}))
+	client.SetLoginBlockWait(5 * time.Second)
+
+	start := time.Now()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("connected after %s, want the 1s block waited out", d)
+	}
+}
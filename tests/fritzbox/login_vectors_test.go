@@ -0,0 +1,50 @@
+package fritzbox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+// TestLoginKnownVectors logs in to a box that hands out the challenges from AVM's
+// login documentation and only accepts the published responses.
+func TestLoginKnownVectors(t *testing.T) {
+	for _, tc := range []struct {
+		password  string
+		challenge string
+		response  string
+	}{
+		// AVM examples
+		{"1example!", "2$10000$5A1711$2000$5A1722", "5A1722$1798a1672bca7c6463d6b245f82b53703b0f50813401b03e4045a5861e689adb"},
+		{"äbc", "1234567z", "1234567z-9e224a41eeefa284df7bb0f26c2913e2"},
+		// computed with Python's hashlib
+		{"1example!", "fa4a6a3e", "fa4a6a3e-4fccf76241da64aa7325dcbd41e87308"},
+		{"äbc", "2$10$6a76a3e4bbfe3faf767f76ead06b9d7f$10$e118a231284a4bf4bceaa8b17cfd0009", "e118a231284a4bf4bceaa8b17cfd0009$840c8100dbcfbffa54aa67e3f26b18780f57a6fb9bc2cd7fd5ca63ca8380cbbc"},
+	} {
+		var got string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/login_sid.lua" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = r.ParseForm()
+			w.Header().Set("Content-Type", "text/xml")
+			if got = r.Form.Get("response"); got == tc.response {
+				fmt.Fprint(w, `<SessionInfo><SID>0123456789abcdef</SID><Challenge></Challenge><BlockTime>0</BlockTime>`+
+					`<Rights><Name>HomeAuto</Name><Access>2</Access></Rights></SessionInfo>`)
+				return
+			}
+			fmt.Fprintf(w, `<SessionInfo><SID>0000000000000000</SID><Challenge>%s</Challenge><BlockTime>0</BlockTime></SessionInfo>`, tc.challenge)
+		}))
+
+		client := fritzbox.New("user", tc.password)
+		client.BaseUrl = srv.URL + "/"
+		if err := client.Connect(); err != nil {
+			t.Errorf("%s, %s: connect: %v (response %q)", tc.password, tc.challenge, err, got)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package fritzbox
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/aha"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+// metricsRecorder records everything it observes.
+type metricsRecorder struct {
+	mu       sync.Mutex
+	requests []fritzbox.RequestStats
+	relogins []error
+}
+
+func (r *metricsRecorder) ObserveRequest(s fritzbox.RequestStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, s)
+}
+
+func (r *metricsRecorder) ObserveRelogin(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.relogins = append(r.relogins, err)
+}
+
+// last returns the last observed request and clears the record.
+func (r *metricsRecorder) last(t *testing.T) fritzbox.RequestStats {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("no request observed")
+	}
+	s := r.requests[len(r.requests)-1]
+	r.requests = nil
+	return s
+}
+
+func TestMetrics(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+
+	var rec metricsRecorder
+	client.SetMetrics(&rec)
+
+	if _, err := aha.GetDeviceList(client); err != nil {
+		t.Fatalf("device list: %v", err)
+	}
+	s := rec.last(t)
+	if s.API != "aha" || s.Method != http.MethodGet || s.Endpoint != "webservices/homeautoswitch.lua?switchcmd=getdevicelistinfos" ||
+		s.StatusCode != http.StatusOK || s.Err != nil || s.BytesReceived == 0 || s.Duration <= 0 {
+		t.Errorf("aha stats = %+v", s)
+	}
+
+	if _, err := rest.GetOverviewUnitByUID(client, uid); err != nil {
+		t.Fatalf("get unit: %v", err)
+	}
+	s = rec.last(t)
+	if s.API != "rest" || s.Endpoint != "api/v0/smarthome/overview/units/{id}" || s.StatusCode != http.StatusOK || s.BytesReceived == 0 {
+		t.Errorf("rest stats = %+v", s)
+	}
+
+	if err := rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{}); err != nil {
+		t.Fatalf("put unit: %v", err)
+	}
+	if s = rec.last(t); s.Method != http.MethodPut || s.BytesSent == 0 || s.StatusCode != http.StatusNoContent {
+		t.Errorf("put stats = %+v", s)
+	}
+
+	if _, err := rest.GetOverviewUnitByUID(client, "unknown"); err == nil {
+		t.Fatal("unknown unit found")
+	}
+	if s = rec.last(t); s.StatusCode != http.StatusNotFound || s.RestCode != rest.CodeUIDNotFound || s.Err != nil {
+		t.Errorf("not found stats = %+v", s)
+	}
+
+	srv.ExpireSessions()
+	if _, err := rest.GetOverviewUnitByUID(client, uid); err != nil {
+		t.Fatalf("get unit after expiry: %v", err)
+	}
+	if s = rec.last(t); s.StatusCode != http.StatusOK {
+		t.Errorf("stats after re-login = %+v", s)
+	}
+	if len(rec.relogins) != 1 || rec.relogins[0] != nil {
+		t.Errorf("relogins = %v", rec.relogins)
+	}
+}
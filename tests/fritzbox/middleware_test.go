@@ -0,0 +1,73 @@
+package fritzbox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func TestRedact(t *testing.T) {
+	for in, want := range map[string]string{
+		"login_sid.lua?sid=0123456789abcdef&version=2": "login_sid.lua?sid=REDACTED&version=2",
+		"username=user&response=2$10$ab$10$cd":         "username=user&response=REDACTED",
+		"username=user&password=secret":                "username=user&password=REDACTED",
+		"SID=0123456789abcdef":                         "SID=REDACTED",
+		"sid=0000000000000000&page=log":                "sid=0000000000000000&page=log",
+		"sid=&page=log":                                "sid=&page=log",
+		"AVM-SID 0123456789abcdef":                     "AVM-SID REDACTED",
+		"<SID>0123456789abcdef</SID>":                  "<SID>REDACTED</SID>",
+		"<SID>0000000000000000</SID>":                  "<SID>0000000000000000</SID>",
+		`{"sid":"0123456789abcdef","data":{}}`:         `{"sid":"REDACTED","data":{}}`,
+		`{"password": "secret", "name": "box"}`:        `{"password": "REDACTED", "name": "box"}`,
+		`{"Response":"abc"}`:                           `{"Response":"REDACTED"}`,
+		`username="u", response="ab", cnonce="c"`:      `username="REDACTED", response="REDACTED", cnonce="REDACTED"`,
+		"GET /overview":                                "GET /overview",
+	} {
+		if got := fritzbox.Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDebugLogger(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+
+	var buf bytes.Buffer
+	client := srv.NewClient()
+	client.Use(fritzbox.DebugLogger(&buf))
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	sid := client.SID()
+	if _, err := smart.GetThermostat(client, uid); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"--> POST " + srv.URL + "/login_sid.lua",
+		"response=REDACTED",
+		"<SID>REDACTED</SID>",
+		"Authorization: AVM-SID REDACTED",
+		"<-- 200 OK",
+		"Office",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	for _, secret := range []string{sid, "secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output contains %q:\n%s", secret, out)
+		}
+	}
+}
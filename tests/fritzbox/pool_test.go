@@ -0,0 +1,64 @@
+package fritzbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+
+	repeater := fritztest.NewServer("user", "secret")
+	defer repeater.Close()
+	repeater.Update(func(m *fritztest.Model) {
+		m.RadioBases = []rest.EndpointRadioBases{{Serial: "B"}}
+	})
+
+	master := fritztest.NewServer("user", "secret")
+	defer master.Close()
+	master.AddThermostat("09995 0000001", "Office", 20)
+	master.AddThermostat("09995 0000002", "Bedroom", 18)
+	master.Update(func(m *fritztest.Model) {
+		m.RadioBases = []rest.EndpointRadioBases{{Serial: "A", IsSmarthomeMaster: true}, {Serial: "B"}}
+	})
+
+	pool := fritzbox.NewPool()
+	pool.Add("repeater", repeater.NewClient())
+	pool.Add("master", master.NewClient())
+	if err := pool.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+
+	mc, err := pool.Master(ctx)
+	if err != nil {
+		t.Fatalf("master: %v", err)
+	}
+	if mc != pool.Client("master") {
+		t.Errorf("master = %v", mc)
+	}
+	thermostats, err := smart.GetAllThermostats(mc)
+	if err != nil || len(thermostats) != 2 {
+		t.Fatalf("thermostats = %v, %v", thermostats, err)
+	}
+
+	bases, err := fritzbox.FanOutMerge(ctx, pool, rest.GetRadioBasesList)
+	if err != nil {
+		t.Fatalf("fan out: %v", err)
+	}
+	if len(bases) != 3 || bases[0].Box != "repeater" || bases[0].Value.Serial != "B" || bases[1].Box != "master" {
+		t.Errorf("bases = %+v", bases)
+	}
+
+	// a failing box does not hide the results of the others
+	repeater.Close()
+	bases, err = fritzbox.FanOutMerge(ctx, pool, rest.GetRadioBasesList)
+	if err == nil || len(bases) != 2 || bases[0].Box != "master" {
+		t.Errorf("with one box down: %+v, %v", bases, err)
+	}
+}
@@ -0,0 +1,152 @@
+package fritzbox
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func TestLimitsMaxInFlight(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	client := newServerClient(t, srv)
+	client.SetLimits(fritzbox.Limits{MaxInFlight: 2})
+
+	var inFlight, peak atomic.Int32
+	gate := make(chan struct{})
+	client.Use(func(next fritzbox.RoundTripFunc) fritzbox.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-gate
+			return next(req)
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.RestGet("api/v0/smarthome/overview/units"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// two requests hold the slots, the others wait for them
+	waitQueued(t, client, 6)
+	close(gate)
+	wg.Wait()
+
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak in flight = %d, want 2", p)
+	}
+}
+
+func TestLimitsPriority(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	client := newServerClient(t, srv)
+	client.SetLimits(fritzbox.Limits{
+		MaxInFlight:  1,
+		PathPriority: map[string]fritzbox.Priority{"api/v0/smarthome/overview": fritzbox.PriorityLow},
+	})
+
+	var mu sync.Mutex
+	var order []string
+	block := make(chan struct{})
+	started := make(chan struct{})
+	client.Use(func(next fritzbox.RoundTripFunc) fritzbox.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/blocker") {
+				close(started)
+				<-block
+			}
+			mu.Lock()
+			order = append(order, req.Method+" "+strings.TrimPrefix(req.URL.Path, "/api/v0/smarthome/"))
+			mu.Unlock()
+			return next(req)
+		}
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.RestGet("api/v0/smarthome/blocker")
+	}()
+	<-started
+
+	// queue low priority polls, then a write, while the blocker holds the only slot
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.RestGet("api/v0/smarthome/overview/units")
+		}()
+	}
+	waitQueued(t, client, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx := fritzbox.WithPriority(context.Background(), fritzbox.PriorityHigh)
+		client.WithContext(ctx).RestGet("api/v0/smarthome/overview/units/" + uid)
+	}()
+	waitQueued(t, client, 4)
+	close(block)
+	wg.Wait()
+
+	if len(order) != 5 || order[1] != "GET overview/units/"+uid {
+		t.Errorf("order = %q", order)
+	}
+}
+
+func TestLimitsRate(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := newServerClient(t, srv)
+	client.SetLimits(fritzbox.Limits{Rate: 20, Burst: 1})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, _, err := client.RestGet("api/v0/smarthome/overview/globals"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request uses the burst token, the others wait 50ms each
+	if d := time.Since(start); d < 180*time.Millisecond {
+		t.Errorf("5 requests at 20/s took %v", d)
+	}
+}
+
+func TestLimitsCancel(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := newServerClient(t, srv)
+	client.SetLimits(fritzbox.Limits{Rate: 0.1, Burst: 1})
+	defer client.SetLimits(fritzbox.Limits{}) // don't make the logout wait
+
+	if _, _, err := client.RestGet("api/v0/smarthome/overview/globals"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.WithContext(ctx).RestGet("api/v0/smarthome/overview/globals"); err == nil {
+		t.Error("request waiting for a token was not cancelled")
+	}
+}
@@ -0,0 +1,236 @@
+package fritzbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/aha"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+// failFirst returns a middleware that answers the first n requests to paths containing
+// path with fail instead of sending them.
+func failFirst(n int32, path string, fail func(*http.Request) (*http.Response, error)) (fritzbox.Middleware, *atomic.Int32) {
+	var calls atomic.Int32
+	return func(next fritzbox.RoundTripFunc) fritzbox.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if !strings.Contains(req.URL.Path, path) {
+				return next(req)
+			}
+			if calls.Add(1) <= n {
+				return fail(req)
+			}
+			return next(req)
+		}
+	}, &calls
+}
+
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		Request:    req,
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+
+	var events []fritzbox.RetryEvent
+	client.SetRetryPolicy(fritzbox.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		OnRetry:     func(ev fritzbox.RetryEvent) { events = append(events, ev) },
+	})
+
+	mw, calls := failFirst(2, "overview/units", func(req *http.Request) (*http.Response, error) {
+		if len(events) == 0 {
+			return response(req, http.StatusServiceUnavailable, ""), nil
+		}
+		return response(req, http.StatusBadRequest, `{"errors":[{"code":3006}]}`), nil
+	})
+	client.Use(mw)
+
+	// a PUT answered with busy was not applied, so it is retried
+	if err := smart.NewThermostatHandle(client, uid).SetTargetTemperature(22); err != nil {
+		t.Fatalf("set target: %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+	if len(events) != 2 || events[0].StatusCode != 503 || events[1].StatusCode != 400 || events[1].Attempt != 2 {
+		t.Errorf("events = %+v", events)
+	}
+	if u, _ := srv.Unit(uid); *u.Interfaces.ThermostatInterface.SetPointTemperature.Celsius != 22 {
+		t.Error("target temperature not applied")
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+	client.SetRetryPolicy(fritzbox.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	resetErr := func(req *http.Request) (*http.Response, error) {
+		return nil, syscall.ECONNRESET
+	}
+
+	// reads are retried
+	mw, calls := failFirst(1, "overview/units", resetErr)
+	client.Use(mw)
+	if _, status, err := client.RestGet("api/v0/smarthome/overview/units"); err != nil || status != 200 {
+		t.Fatalf("get: %d %v", status, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("get attempts = %d, want 2", n)
+	}
+
+	// plain writes are not, the box may have applied them
+	calls.Store(0)
+	body := map[string]any{"name": "Study"}
+	if _, _, err := client.RestPut("api/v0/smarthome/overview/units/"+uid, body); err == nil {
+		t.Error("put after connection reset was retried")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("put attempts = %d, want 1", n)
+	}
+
+	// unless marked as idempotent
+	calls.Store(0)
+	idem := client.WithContext(fritzbox.WithIdempotent(context.Background()))
+	if _, _, err := idem.RestPut("api/v0/smarthome/overview/units/"+uid, body); err != nil {
+		t.Errorf("idempotent put: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("idempotent put attempts = %d, want 2", n)
+	}
+}
+
+// TestRetryTxBusy checks that reads reporting a DECT device as txbusy are repeated,
+// and writes are not.
+func TestRetryTxBusy(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+	client.SetRetryPolicy(fritzbox.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond})
+
+	var reads atomic.Int32
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/homeautoswitch.lua") {
+			reads.Add(1)
+		}
+	}, nil))
+
+	srv.SetTxBusy("09995 0000001", 2)
+	dl, err := aha.GetDeviceList(client)
+	if err != nil {
+		t.Fatalf("device list: %v", err)
+	}
+	if n := reads.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+	if dl.Devices[0].Txbusy != "0" {
+		t.Errorf("txbusy = %q", dl.Devices[0].Txbusy)
+	}
+
+	mw, calls := failFirst(1, "homeautoswitch.lua", func(req *http.Request) (*http.Response, error) {
+		resp := response(req, http.StatusOK, "<device><txbusy>1</txbusy></device>")
+		resp.Header.Set("Content-Type", "text/xml")
+		return resp, nil
+	})
+	client.Use(mw)
+	data := fritzbox.Values{"switchcmd": "sethkrtsoll", "ain": "099950000001", "param": "36", "sid": client.SID()}
+	if _, _, err := client.AhaRequestString(http.MethodGet, "webservices/homeautoswitch.lua", data); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("write attempts = %d, want 1", n)
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := newServerClient(t, srv)
+	client.SetRetryPolicy(fritzbox.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	mw, calls := failFirst(10, "overview/globals", func(req *http.Request) (*http.Response, error) {
+		return response(req, http.StatusServiceUnavailable, "firmware update"), nil
+	})
+	client.Use(mw)
+
+	_, status, err := client.RestGet("api/v0/smarthome/overview/globals")
+	if err != nil || status != http.StatusServiceUnavailable {
+		t.Errorf("get = %d, %v", status, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+
+	// cancellation stops waiting for the next attempt
+	client.SetRetryPolicy(fritzbox.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour})
+	defer client.SetRetryPolicy(fritzbox.RetryPolicy{}) // don't make the logout wait
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.WithContext(ctx).RestGet("api/v0/smarthome/overview/globals"); err == nil {
+		t.Error("retry wait was not cancelled")
+	}
+}
+
+// TestRetryHoldsSlot checks that a response buffered for Retryable keeps its limiter
+// slot until the caller closes it.
+func TestRetryHoldsSlot(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+	client.SetLimits(fritzbox.Limits{MaxInFlight: 1})
+	client.SetRetryPolicy(fritzbox.RetryPolicy{
+		MaxAttempts: 2,
+		Retryable:   func(*http.Request, *http.Response, error) bool { return false },
+	})
+	defer client.SetLimits(fritzbox.Limits{})
+
+	data := fritzbox.Values{"switchcmd": "getdevicelistinfos", "sid": client.SID()}
+	resp, err := client.AhaRequest(http.MethodGet, "webservices/homeautoswitch.lua", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, _, err := client.AhaRequestString(http.MethodGet, "webservices/homeautoswitch.lua", data)
+		done <- err
+	}()
+	waitQueued(t, client, 1)
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || !bytes.Contains(body, []byte("Office")) {
+		t.Errorf("body = %q, %v", body, err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("second request: %v", err)
+	}
+}
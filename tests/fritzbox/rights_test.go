@@ -0,0 +1,66 @@
+package fritzbox
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func TestRightsCheck(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.SetRights(fritzbox.Rights{fritzbox.RightHomeAuto: fritzbox.AccessRead})
+	client := newServerClient(t, srv)
+	client.SetRightsCheck(true)
+
+	var sent atomic.Int32
+	client.Use(fritzbox.Hooks(func(*http.Request) { sent.Add(1) }, nil))
+
+	if _, err := smart.GetThermostat(client, uid); err != nil {
+		t.Fatalf("read with read access: %v", err)
+	}
+
+	sent.Store(0)
+	rejected := []struct {
+		name string
+		call func() error
+	}{
+		{"REST write", func() error {
+			return rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{})
+		}},
+		{"AHA write", func() error {
+			_, _, err := client.AhaRequestString(http.MethodGet, "webservices/homeautoswitch.lua",
+				fritzbox.Values{"switchcmd": "sethkrtsoll", "ain": "099950000001", "param": "40", "sid": client.SID()})
+			return err
+		}},
+		{"data.lua without BoxAdmin", func() error {
+			_, _, err := client.AhaRequestString(http.MethodPost, "data.lua", fritzbox.Values{"page": "log", "sid": client.SID()})
+			return err
+		}},
+	}
+	for _, tc := range rejected {
+		if err := tc.call(); !errors.Is(err, fritzbox.ErrInsufficientRights) {
+			t.Errorf("%s: err = %v, want ErrInsufficientRights", tc.name, err)
+		}
+	}
+	if n := sent.Load(); n != 0 {
+		t.Errorf("%d requests sent despite missing rights", n)
+	}
+
+	// without the check the box itself refuses the write
+	client.SetRightsCheck(false)
+	err := rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{})
+	if !errors.Is(err, rest.ErrNoPermission) {
+		t.Errorf("unchecked REST write: err = %v, want ErrNoPermission", err)
+	}
+	if sent.Load() == 0 {
+		t.Error("unchecked REST write was not sent")
+	}
+}
@@ -0,0 +1,134 @@
+package fritzbox
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	store := fritzbox.NewFileSessionStore(path)
+
+	if s, err := store.Load(); s != nil || err != nil {
+		t.Fatalf("load without file = %v, %v; want nil, nil", s, err)
+	}
+
+	want := fritzbox.StoredSession{BaseURL: "http://192.168.178.1/", Username: "user", SID: "0123456789abcdef"}
+	if err := store.Save(&want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0o600 {
+		t.Errorf("mode = %o, want 600", mode)
+	}
+	got, err := store.Load()
+	if err != nil || got == nil || *got != want {
+		t.Fatalf("load = %+v, %v; want %+v", got, err, want)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if s, err := store.Load(); s != nil || err != nil {
+		t.Errorf("load after clear = %v, %v; want nil, nil", s, err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Errorf("clear without file: %v", err)
+	}
+}
+
+// newStoreClient returns a client using the session file at path that counts the
+// requests posted to login_sid.lua.
+func newStoreClient(srv *fritztest.Server, path string, posts *atomic.Int32) *fritzbox.Client {
+	client := srv.NewClient()
+	client.SetSessionStore(fritzbox.NewFileSessionStore(path))
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if isLogin(req) {
+			posts.Add(1)
+		}
+	}, nil))
+	return client
+}
+
+func TestSessionStoreResume(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	var posts atomic.Int32
+	first := newStoreClient(srv, path, &posts)
+	if err := first.Connect(); err != nil {
+		t.Fatalf("first connect: %v", err)
+	}
+	// the first process ends without Close, leaving the session on the box
+
+	second := newStoreClient(srv, path, &posts)
+	if err := second.Connect(); err != nil {
+		t.Fatalf("second connect: %v", err)
+	}
+	if second.SID() != first.SID() {
+		t.Errorf("resumed SID %s, want %s", second.SID(), first.SID())
+	}
+	if n := posts.Load(); n != 1 {
+		t.Errorf("%d logins, want 1", n)
+	}
+	if n := srv.Sessions(); n != 1 {
+		t.Errorf("%d sessions, want 1", n)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("session file left after close: %v", err)
+	}
+}
+
+func TestSessionStoreStale(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+	store := fritzbox.NewFileSessionStore(path)
+	stale := fritzbox.StoredSession{BaseURL: srv.URL + "/", Username: "user", SID: "0123456789abcdef"}
+
+	// a stale session falls back to a login and is replaced
+	if err := store.Save(&stale); err != nil {
+		t.Fatal(err)
+	}
+	var posts atomic.Int32
+	client := newStoreClient(srv, path, &posts)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if n := posts.Load(); n != 1 {
+		t.Errorf("%d logins, want 1", n)
+	}
+	got, err := store.Load()
+	if err != nil || got == nil || got.SID != client.SID() {
+		t.Errorf("stored = %+v, %v; want SID %s", got, err, client.SID())
+	}
+
+	// it is also removed if the login fails
+	if err := store.Save(&stale); err != nil {
+		t.Fatal(err)
+	}
+	wrong := fritzbox.New("user", "wrong")
+	wrong.BaseUrl = srv.URL + "/"
+	wrong.SetSessionStore(store)
+	if err := wrong.Connect(); err == nil {
+		t.Fatal("connect with wrong password succeeded")
+	}
+	if s, err := store.Load(); s != nil || err != nil {
+		t.Errorf("stored after failed login = %+v, %v; want nil", s, err)
+	}
+}
@@ -0,0 +1,102 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func TestTLSPinning(t *testing.T) {
+	srv := fritztest.NewTLSServer("user", "secret")
+	defer srv.Close()
+	fingerprint := fritzbox.CertificateFingerprint(srv.Certificate().Raw)
+
+	got, err := fritzbox.FetchFingerprint(context.Background(), srv.URL)
+	if err != nil || got != fingerprint {
+		t.Fatalf("fetch fingerprint = %q, %v", got, err)
+	}
+
+	client := srv.NewClient()
+	if err := client.SetTLSConfig(fritzbox.PinnedTLSConfig(strings.ToUpper(fingerprint))); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect with pinned fingerprint: %v", err)
+	}
+	client.Close()
+
+	if err := client.SetTLSConfig(fritzbox.PinnedTLSConfig(strings.Repeat("00", 32))); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(); !errors.Is(err, fritzbox.ErrFingerprintMismatch) {
+		t.Fatalf("connect with wrong fingerprint = %v", err)
+	}
+}
+
+func TestSetTLSConfigKeepsTransport(t *testing.T) {
+	cfg := fritzbox.PinnedTLSConfig(strings.Repeat("00", 32))
+	client := fritzbox.New("user", "secret")
+	client.SetHTTPClient(&http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 7}, Timeout: 3 * time.Second})
+	if err := client.SetTLSConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	hc := client.HTTPClient()
+	transport, ok := hc.Transport.(*http.Transport)
+	if !ok || transport.MaxIdleConnsPerHost != 7 || transport.TLSClientConfig != cfg || hc.Timeout != 3*time.Second {
+		t.Errorf("client = %+v, transport = %+v", hc, hc.Transport)
+	}
+
+	custom := &http.Client{Transport: roundTripFunc(http.DefaultTransport.RoundTrip)}
+	client.SetHTTPClient(custom)
+	if err := client.SetTLSConfig(cfg); err == nil {
+		t.Error("custom transport: no error")
+	}
+	if client.HTTPClient() != custom {
+		t.Error("custom transport replaced")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestTLSTrustOnFirstUse(t *testing.T) {
+	srv := fritztest.NewTLSServer("user", "secret")
+	defer srv.Close()
+	store := fritzbox.NewFileFingerprintStore(filepath.Join(t.TempDir(), "fingerprints"))
+	host := "127.0.0.1"
+
+	for i := 0; i < 2; i++ {
+		client := srv.NewClient()
+		if err := client.SetTLSConfig(fritzbox.TOFUTLSConfig(store, host)); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("connect %d: %v", i, err)
+		}
+		client.Close()
+	}
+
+	pinned, err := store.LoadFingerprint(host)
+	if err != nil || pinned != fritzbox.CertificateFingerprint(srv.Certificate().Raw) {
+		t.Fatalf("pinned = %q, %v", pinned, err)
+	}
+
+	if err := store.SaveFingerprint(host, strings.Repeat("ab:", 31)+"ab"); err != nil {
+		t.Fatal(err)
+	}
+	client := srv.NewClient()
+	if err := client.SetTLSConfig(fritzbox.TOFUTLSConfig(store, host)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(); !errors.Is(err, fritzbox.ErrFingerprintMismatch) {
+		t.Fatalf("connect after certificate change = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package fritzbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+func TestTwoFactorManual(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.SetTwoFactor(true)
+	client := newServerClient(t, srv)
+
+	put := func() error { return rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{}) }
+	err := put()
+	if !errors.Is(err, fritzbox.ErrTwoFactorRequired) || !errors.Is(err, rest.Err2FANeeded) {
+		t.Fatalf("err = %v, want ErrTwoFactorRequired", err)
+	}
+	// the rejection must not end the session
+	if srv.Sessions() != 1 {
+		t.Errorf("sessions = %d, want 1", srv.Sessions())
+	}
+
+	ctx := context.Background()
+	err = client.ConfirmTwoFactor(ctx, func(ctx context.Context, ch *fritzbox.TwoFactorChallenge) error {
+		if !ch.Offers(fritzbox.TwoFactorButton) || !ch.Offers(fritzbox.TwoFactorDTMF) || ch.DTMFCode != fritztest.TwoFactorCode {
+			t.Errorf("challenge = %+v", ch)
+		}
+		// a second challenge is refused while the first one runs
+		if _, err := client.StartTwoFactor(ctx); !errors.Is(err, fritzbox.ErrTwoFactorBusy) {
+			t.Errorf("second start: %v, want ErrTwoFactorBusy", err)
+		}
+		srv.ConfirmTwoFactor()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if err := put(); err != nil {
+		t.Fatalf("put after confirmation: %v", err)
+	}
+
+	// the confirmation is used up
+	if err := put(); !errors.Is(err, fritzbox.ErrTwoFactorRequired) {
+		t.Errorf("second put: %v, want ErrTwoFactorRequired", err)
+	}
+}
+
+func TestTwoFactorHandler(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.SetTwoFactor(true)
+	client := newServerClient(t, srv)
+
+	var prompts int
+	client.SetTwoFactorHandler(func(ctx context.Context, ch *fritzbox.TwoFactorChallenge) error {
+		prompts++
+		srv.ConfirmTwoFactor()
+		return nil
+	})
+	if err := rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if prompts != 1 {
+		t.Errorf("prompts = %d, want 1", prompts)
+	}
+
+	// an error of the handler aborts the request
+	abort := errors.New("cancelled by user")
+	client.SetTwoFactorHandler(func(ctx context.Context, ch *fritzbox.TwoFactorChallenge) error {
+		return abort
+	})
+	if err := rest.PutOverviewUnit(client, uid, &rest.EndpointOverviewPutUnit{}); !errors.Is(err, abort) {
+		t.Errorf("err = %v, want handler error", err)
+	}
+}
+
+func TestTwoFactorTimeout(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.SetTwoFactor(true)
+	client := newServerClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.ConfirmTwoFactor(ctx, func(ctx context.Context, ch *fritzbox.TwoFactorChallenge) error {
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+}
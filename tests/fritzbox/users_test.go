@@ -0,0 +1,37 @@
+package fritzbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+func TestLastUser(t *testing.T) {
+	srv := fritztest.NewServer("fritz1234", "secret")
+	defer srv.Close()
+
+	users, err := fritzbox.ListUsers(srv.URL)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if len(users) != 1 || users[0] != (fritzbox.User{Name: "fritz1234", Last: true}) {
+		t.Errorf("users = %v", users)
+	}
+
+	client := fritzbox.New("", "secret")
+	client.BaseUrl = srv.URL
+	if err := client.Connect(); !errors.Is(err, fritzbox.ErrInvalidCredentials) {
+		t.Fatalf("connect without username = %v", err)
+	}
+
+	client.SetUseLastUser(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect with last user: %v", err)
+	}
+	defer client.Close()
+	if client.LoginUser() != "fritz1234" || client.Username != "" {
+		t.Errorf("login user = %q, Username = %q", client.LoginUser(), client.Username)
+	}
+}
@@ -0,0 +1,113 @@
+package fritztest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+)
+
+const testSID = "1234567890abcdef"
+
+// newBox returns a minimal box that accepts any login and answers one REST endpoint.
+func newBox(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/login_sid.lua" && r.Form.Get("logout") != "":
+			fmt.Fprint(w, "<SessionInfo><SID>0000000000000000</SID></SessionInfo>")
+		case r.URL.Path == "/login_sid.lua" && r.Form.Get("response") != "":
+			fmt.Fprintf(w, "<SessionInfo><SID>%s</SID><Challenge>1234abcd</Challenge><BlockTime>0</BlockTime></SessionInfo>", testSID)
+		case r.URL.Path == "/login_sid.lua":
+			fmt.Fprint(w, "<SessionInfo><SID>0000000000000000</SID><Challenge>1234abcd</Challenge><BlockTime>0</BlockTime></SessionInfo>")
+		case r.URL.Path == "/api/v0/smarthome/overview/globals":
+			if r.Header.Get("Authorization") != "AVM-SID "+testSID {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"sid":"`+testSID+`","secret-user":"fritz1234"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRecordReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	box := newBox(t)
+
+	run := func(mode fritztest.Mode) string {
+		rec, err := fritztest.NewRecorder(cassette, mode)
+		if err != nil {
+			t.Fatalf("NewRecorder failed: %v", err)
+		}
+		rec.Secrets = []string{"fritz1234"}
+
+		cl := fritzbox.New("fritz1234", "secret")
+		cl.BaseUrl = box.URL
+		cl.SetHTTPClient(rec.HTTPClient())
+		if err := cl.Connect(); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		body, status, err := cl.RestGet("api/v0/smarthome/overview/globals")
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("RestGet failed: %d %v", status, err)
+		}
+		if err := cl.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if err := rec.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		return string(body)
+	}
+
+	recorded := run(fritztest.ModeAuto)
+	if !strings.Contains(recorded, testSID) {
+		t.Errorf("client should see the real response while recording, got %s", recorded)
+	}
+
+	raw, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+	for _, secret := range []string{testSID, "fritz1234"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("cassette contains secret %q", secret)
+		}
+	}
+
+	// replay must not touch the box
+	box.Close()
+	replayed := run(fritztest.ModeAuto)
+	if strings.Contains(replayed, testSID) {
+		t.Errorf("replayed response should be scrubbed, got %s", replayed)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(cassette, []byte(`{"interactions":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := fritztest.NewRecorder(cassette, fritztest.ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	cl := fritzbox.New("user", "pass")
+	cl.BaseUrl = "http://fritz.box/"
+	cl.SetHTTPClient(rec.HTTPClient())
+	if err := cl.Connect(); err == nil {
+		t.Error("Connect should fail for a request missing from the cassette")
+	}
+}
@@ -0,0 +1,129 @@
+package fritztest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/aha"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/smart"
+)
+
+func newServerClient(t *testing.T, srv *fritztest.Server) *fritzbox.Client {
+	t.Helper()
+	client := srv.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestServerLogin(t *testing.T) {
+	for _, md5 := range []bool{false, true} {
+		srv := fritztest.NewServer("user", "pässword")
+		srv.SetLegacyMD5(md5)
+
+		client := newServerClient(t, srv)
+		if !client.Rights().Has(fritzbox.RightHomeAuto, fritzbox.AccessWrite) {
+			t.Errorf("md5=%v: rights = %v", md5, client.Rights())
+		}
+		if err := client.Close(); err != nil {
+			t.Errorf("md5=%v: close: %v", md5, err)
+		}
+		if n := srv.Sessions(); n != 0 {
+			t.Errorf("md5=%v: %d sessions after logout", md5, n)
+		}
+
+		bad := fritzbox.New("user", "wrong")
+		bad.BaseUrl = srv.URL + "/"
+		if err := bad.Connect(); !errors.Is(err, fritzbox.ErrInvalidCredentials) {
+			t.Errorf("md5=%v: connect with wrong password = %v", md5, err)
+		}
+		srv.Close()
+	}
+}
+
+func TestServerThermostat(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 19.5)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+
+	handle := smart.NewThermostatHandle(client, uid)
+	if err := handle.SetTargetTemperature(22.5); err != nil {
+		t.Fatalf("set target: %v", err)
+	}
+	if err := handle.SetComfortPreset(23); err != nil {
+		t.Fatalf("set comfort: %v", err)
+	}
+
+	th, err := handle.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if th.TargetTemp != 22.5 || th.ComfortTemp != 23 || th.CurrentTemp != 19.5 || th.Name != "Office" {
+		t.Errorf("thermostat = %+v", th)
+	}
+
+	// the AHA view is derived from the same model
+	dl, err := aha.GetDeviceList(client)
+	if err != nil {
+		t.Fatalf("device list: %v", err)
+	}
+	if len(dl.Devices) != 2 {
+		t.Fatalf("devices = %v", dl.Devices)
+	}
+	hkr := aha.GetCapability[*aha.Hkr](dl.Devices[0])
+	if hkr == nil || hkr.Tsoll != "45" || hkr.Komfort != "46" {
+		t.Fatalf("hkr = %+v", hkr)
+	}
+
+	if err := hkr.DECTSetSoll(client, 18); err != nil {
+		t.Fatalf("set soll: %v", err)
+	}
+	unit, _ := srv.Unit(uid)
+	if c := unit.Interfaces.ThermostatInterface.SetPointTemperature.Celsius; c == nil || *c != 18 {
+		t.Errorf("setpoint after AHA write = %v", c)
+	}
+
+	if _, err := smart.GetThermostat(client, "unknown"); !errors.Is(err, smart.ErrNotFound) {
+		t.Errorf("unknown thermostat: %v", err)
+	}
+}
+
+// TestServerAnyPassword checks that the server computes the responses for the
+// password it was given.
+func TestServerAnyPassword(t *testing.T) {
+	for _, md5 := range []bool{false, true} {
+		srv := fritztest.NewServer("admin", "hunter2")
+		srv.SetLegacyMD5(md5)
+
+		client := srv.NewClient()
+		if err := client.Connect(); err != nil {
+			t.Errorf("md5=%v: connect: %v", md5, err)
+		}
+		client.Close()
+		srv.Close()
+	}
+}
+
+func TestServerExpiredSession(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	uid := srv.AddThermostat("09995 0000001", "Office", 20)
+	srv.AddThermostat("09995 0000002", "Bedroom", 17)
+	client := newServerClient(t, srv)
+
+	srv.ExpireSessions()
+	if _, err := smart.GetThermostat(client, uid); err != nil {
+		t.Fatalf("REST after expiry: %v", err)
+	}
+
+	srv.ExpireSessions()
+	if _, err := aha.GetDeviceList(client); err != nil {
+		t.Fatalf("AHA after expiry: %v", err)
+	}
+}
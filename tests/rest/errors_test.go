@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/fritztest"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/rest"
+)
+
+func TestAPIError(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	srv.AddThermostat("09995 0000001", "Office", 20)
+	client := srv.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	sentinels := []error{
+		rest.ErrBadValue, rest.ErrOutOfRange, rest.ErrUIDNotFound, rest.ErrNoPermission,
+		rest.ErrNotSupported, rest.ErrBusy, rest.ErrInternal,
+		rest.Err2FANeeded, rest.Err2FABusy, rest.Err2FABlocked,
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+
+		code    int
+		field   string
+		message string
+		entries int
+		is      []error
+		text    string
+	}{
+		{
+			name: "uid not found", status: 404, body: `{"errors":[{"code":2001,"field":"UID"}]}`,
+			code: rest.CodeUIDNotFound, field: "UID", entries: 1, is: []error{rest.ErrUIDNotFound},
+			text: "unexpected status 404: code 2001 (field UID): uid not found",
+		},
+		{
+			name: "message", status: 400, body: `{"errors":[{"code":1002,"field":"temperature","message":"too hot"}]}`,
+			code: rest.CodeOutOfRange, field: "temperature", message: "too hot", entries: 1, is: []error{rest.ErrOutOfRange},
+			text: "unexpected status 400: code 1002 (field temperature): too hot",
+		},
+		{
+			name: "several entries", status: 400, body: `{"errors":[{"code":1000,"field":"name"},{"code":3006}]}`,
+			code: rest.CodeBadValue, field: "name", entries: 2, is: []error{rest.ErrBadValue, rest.ErrBusy},
+		},
+		{
+			name: "unsupported version", status: 400, body: `{"errors":[{"code":3011}]}`,
+			code: rest.CodeNotSupportedVersion, entries: 1, is: []error{rest.ErrNotSupported},
+		},
+		{
+			name: "second factor", status: 403, body: `{"errors":[{"code":3008}]}`,
+			code: rest.Code2FANeeded, entries: 1, is: []error{rest.Err2FANeeded, fritzbox.ErrTwoFactorRequired},
+		},
+		{
+			name: "internal", status: 500, body: `{"errors":[{"code":4000}]}`,
+			code: rest.CodeInternal, entries: 1, is: []error{rest.ErrInternal},
+		},
+		{
+			name: "code without sentinel", status: 404, body: `{"errors":[{"code":3002}]}`,
+			code: rest.CodeBadPath, entries: 1,
+			text: "unexpected status 404: code 3002",
+		},
+		{
+			name: "no error list", status: 502, body: `bad gateway`,
+			text: "unexpected status 502: bad gateway",
+		},
+		{
+			name: "empty error list", status: 400, body: `{"errors":[]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv.SetRestError(tc.status, tc.body)
+			defer srv.SetRestError(0, "")
+
+			_, err := rest.GetOverviewUnitsList(client)
+			var apiErr *rest.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tc.status || apiErr.Code != tc.code || apiErr.Field != tc.field ||
+				apiErr.Message != tc.message || len(apiErr.Errors) != tc.entries || string(apiErr.Body) != tc.body {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+			if tc.entries > 0 && !apiErr.HasCode(tc.code) {
+				t.Errorf("HasCode(%d) = false", tc.code)
+			}
+			if tc.text != "" && err.Error() != tc.text {
+				t.Errorf("Error() = %q, want %q", err.Error(), tc.text)
+			}
+
+			for _, sentinel := range sentinels {
+				want := false
+				for _, is := range tc.is {
+					want = want || sentinel == is
+				}
+				if got := errors.Is(err, sentinel); got != want {
+					t.Errorf("errors.Is(err, %q) = %v, want %v", sentinel, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestAPIErrorFromServer checks the errors the fake box produces itself.
+func TestAPIErrorFromServer(t *testing.T) {
+	srv := fritztest.NewServer("user", "secret")
+	defer srv.Close()
+	client := srv.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	_, err := rest.GetOverviewUnitByUID(client, "missing")
+	if !errors.Is(err, rest.ErrUIDNotFound) {
+		t.Errorf("unknown uid: %v, want ErrUIDNotFound", err)
+	}
+	var apiErr *rest.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || apiErr.Field != "UID" {
+		t.Errorf("unknown uid: %+v", apiErr)
+	}
+}
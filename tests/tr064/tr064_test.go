@@ -0,0 +1,401 @@
+package tr064
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2/tr064"
+)
+
+const (
+	testUser  = "admin"
+	testPass  = "secret"
+	testRealm = "F!Box SOAP-Auth"
+	testNonce = "6FD2C7E3B1A3B9F5"
+)
+
+const desc = `<?xml version="1.0"?>
+<root xmlns="urn:dslforum-org:device-1-0">
+<device>
+ <deviceType>urn:dslforum-org:device:InternetGatewayDevice:1</deviceType>
+ <serviceList>
+  <service>
+   <serviceType>urn:dslforum-org:service:DeviceInfo:1</serviceType>
+   <serviceId>urn:DeviceInfo-com:serviceId:DeviceInfo1</serviceId>
+   <controlURL>/upnp/control/deviceinfo</controlURL>
+   <eventSubURL>/upnp/control/deviceinfo</eventSubURL>
+   <SCPDURL>/deviceinfoSCPD.xml</SCPDURL>
+  </service>
+  <service>
+   <serviceType>urn:dslforum-org:service:X_AVM-DE_OnTel:1</serviceType>
+   <serviceId>urn:X_AVM-DE_OnTel-com:serviceId:X_AVM-DE_OnTel1</serviceId>
+   <controlURL>/upnp/control/x_contact</controlURL>
+   <eventSubURL>/upnp/control/x_contact</eventSubURL>
+   <SCPDURL>/x_contactSCPD.xml</SCPDURL>
+  </service>
+ </serviceList>
+ <deviceList>
+  <device>
+   <deviceType>urn:dslforum-org:device:LANDevice:1</deviceType>
+   <serviceList>
+    <service>
+     <serviceType>urn:dslforum-org:service:WLANConfiguration:1</serviceType>
+     <serviceId>urn:WLANConfiguration-com:serviceId:WLANConfiguration1</serviceId>
+     <controlURL>/upnp/control/wlanconfig1</controlURL>
+     <eventSubURL>/upnp/control/wlanconfig1</eventSubURL>
+     <SCPDURL>/wlanconfigSCPD.xml</SCPDURL>
+    </service>
+    <service>
+     <serviceType>urn:dslforum-org:service:WLANConfiguration:1</serviceType>
+     <serviceId>urn:WLANConfiguration-com:serviceId:WLANConfiguration2</serviceId>
+     <controlURL>/upnp/control/wlanconfig2</controlURL>
+     <eventSubURL>/upnp/control/wlanconfig2</eventSubURL>
+     <SCPDURL>/wlanconfigSCPD.xml</SCPDURL>
+    </service>
+    <service>
+     <serviceType>urn:dslforum-org:service:Hosts:1</serviceType>
+     <serviceId>urn:LanDeviceHosts-com:serviceId:Hosts1</serviceId>
+     <controlURL>/upnp/control/hosts</controlURL>
+     <eventSubURL>/upnp/control/hosts</eventSubURL>
+     <SCPDURL>/hostsSCPD.xml</SCPDURL>
+    </service>
+   </serviceList>
+  </device>
+ </deviceList>
+</device>
+</root>`
+
+const deviceInfoSCPD = `<?xml version="1.0"?>
+<scpd xmlns="urn:dslforum-org:service-1-0">
+<actionList>
+ <action>
+  <name>GetInfo</name>
+  <argumentList>
+   <argument><name>NewModelName</name><direction>out</direction><relatedStateVariable>ModelName</relatedStateVariable></argument>
+   <argument><name>NewUpTime</name><direction>out</direction><relatedStateVariable>UpTime</relatedStateVariable></argument>
+  </argumentList>
+ </action>
+</actionList>
+<serviceStateTable>
+ <stateVariable sendEvents="no"><name>ModelName</name><dataType>string</dataType></stateVariable>
+ <stateVariable sendEvents="no"><name>UpTime</name><dataType>ui4</dataType></stateVariable>
+</serviceStateTable>
+</scpd>`
+
+const callList = `<?xml version="1.0" encoding="utf-8"?>
+<root>
+<timestamp>1760000000</timestamp>
+<Call><Id>12</Id><Type>1</Type><Caller>0301234567</Caller><Called>SIP: 987654</Called><CalledNumber>987654</CalledNumber><Name>Doorbell</Name><Numbertype>sip</Numbertype><Device>Wohnzimmer</Device><Port>10</Port><Date>16.10.26 18:42</Date><Duration>0:03</Duration></Call>
+<Call><Id>11</Id><Type>3</Type><CallerNumber>987654</CallerNumber><Called>0401111</Called><Name></Name><Device>Küche</Device><Port>11</Port><Date>15.10.26 09:05</Date><Duration>1:12</Duration></Call>
+</root>`
+
+var argPattern = regexp.MustCompile(`<(New\w+)>([^<]*)</New\w+>`)
+
+// fakeBox answers the TR-064 requests of the tests and checks digest authentication.
+type fakeBox struct {
+	wlanEnabled [2]bool
+	requests    int
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *fakeBox) authorized(r *http.Request) bool {
+	h := r.Header.Get("Authorization")
+	params := map[string]string{}
+	for _, m := range regexp.MustCompile(`(\w+)="?([^",]*)"?`).FindAllStringSubmatch(strings.TrimPrefix(h, "Digest "), -1) {
+		params[m[1]] = m[2]
+	}
+	if params["username"] != testUser || params["nonce"] != testNonce {
+		return false
+	}
+	ha1 := md5hex(testUser + ":" + testRealm + ":" + testPass)
+	ha2 := md5hex(r.Method + ":" + params["uri"])
+	want := md5hex(ha1 + ":" + testNonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2)
+	return params["response"] == want
+}
+
+func (b *fakeBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests++
+	switch r.URL.Path {
+	case "/tr64desc.xml":
+		io.WriteString(w, desc)
+		return
+	case "/deviceinfoSCPD.xml":
+		io.WriteString(w, deviceInfoSCPD)
+		return
+	case "/calllist.lua":
+		if r.URL.Query().Get("sid") != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, callList)
+		return
+	}
+
+	if !b.authorized(r) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", algorithm=MD5, qop="auth"`, testRealm, testNonce))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	args := map[string]string{}
+	for _, m := range argPattern.FindAllStringSubmatch(string(body), -1) {
+		args[m[1]] = m[2]
+	}
+	action := r.Header.Get("SoapAction")
+	action = action[strings.Index(action, "#")+1:]
+
+	var out string
+	switch r.URL.Path + "#" + action {
+	case "/upnp/control/deviceinfo#GetInfo":
+		out = `<NewManufacturerName>AVM</NewManufacturerName><NewModelName>FRITZ!Box 7590</NewModelName><NewSoftwareVersion>154.08.20</NewSoftwareVersion><NewUpTime>86400</NewUpTime>`
+	case "/upnp/control/hosts#GetHostNumberOfEntries":
+		out = `<NewHostNumberOfEntries>2</NewHostNumberOfEntries>`
+	case "/upnp/control/hosts#GetGenericHostEntry":
+		switch args["NewIndex"] {
+		case "0":
+			out = `<NewIPAddress>192.168.178.20</NewIPAddress><NewAddressSource>DHCP</NewAddressSource><NewLeaseTimeRemaining>600</NewLeaseTimeRemaining><NewMACAddress>AA:BB:CC:00:00:01</NewMACAddress><NewInterfaceType>802.11</NewInterfaceType><NewActive>1</NewActive><NewHostName>laptop</NewHostName>`
+		case "1":
+			out = `<NewIPAddress>192.168.178.21</NewIPAddress><NewMACAddress>AA:BB:CC:00:00:02</NewMACAddress><NewActive>0</NewActive><NewHostName>printer</NewHostName>`
+		default:
+			writeFault(w, 713, "SpecifiedArrayIndexInvalid")
+			return
+		}
+	case "/upnp/control/wlanconfig1#GetInfo", "/upnp/control/wlanconfig2#GetInfo":
+		n := int(r.URL.Path[len(r.URL.Path)-1] - '1')
+		enable := "0"
+		if b.wlanEnabled[n] {
+			enable = "1"
+		}
+		out = fmt.Sprintf(`<NewEnable>%s</NewEnable><NewStatus>Up</NewStatus><NewSSID>net-%d</NewSSID><NewChannel>%d</NewChannel>`, enable, n+1, 6+30*n)
+	case "/upnp/control/wlanconfig2#SetEnable":
+		b.wlanEnabled[1] = args["NewEnable"] == "1"
+	case "/upnp/control/x_contact#GetCallList":
+		out = `<NewCallListURL>http://fritz.box:49000/calllist.lua?sid=abc</NewCallListURL>`
+	default:
+		writeFault(w, 401, "Invalid Action")
+		return
+	}
+
+	fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:%sResponse xmlns:u="x">%s</u:%sResponse></s:Body></s:Envelope>`, action, out, action)
+}
+
+func writeFault(w http.ResponseWriter, code int, desc string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:dslforum-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`, code, desc)
+}
+
+func newTestClient(t *testing.T, user, pass string) (*tr064.Client, *fakeBox) {
+	t.Helper()
+	box := &fakeBox{}
+	srv := httptest.NewServer(box)
+	t.Cleanup(srv.Close)
+
+	tc := tr064.New(fritzbox.New(user, pass))
+	tc.BaseURL = srv.URL
+	return tc, box
+}
+
+func TestNewBaseURL(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                        "http://192.168.178.1:49000",
+		"http://fritz.box/":       "http://fritz.box:49000",
+		"https://192.168.1.1:443": "https://192.168.1.1:49443",
+	} {
+		c := fritzbox.New("u", "p")
+		c.BaseUrl = base
+		if got := tr064.New(c).BaseURL; got != want {
+			t.Errorf("New(%q).BaseURL = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+	tc, box := newTestClient(t, testUser, testPass)
+	ctx := context.Background()
+
+	info, err := tc.DeviceInfo(ctx)
+	if err != nil {
+		t.Fatalf("device info: %v", err)
+	}
+	if info.ModelName != "FRITZ!Box 7590" || info.SoftwareVersion != "154.08.20" || info.UpTime != 86400 {
+		t.Errorf("info = %+v", info)
+	}
+
+	// the digest challenge is reused
+	box.requests = 0
+	if _, err := tc.DeviceInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if box.requests != 1 {
+		t.Errorf("second call took %d requests", box.requests)
+	}
+
+	svc, err := tc.Service(ctx, tr064.ServiceDeviceInfo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scpd, err := tc.Describe(ctx, svc)
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	action, ok := scpd.Action("GetInfo")
+	if !ok || len(action.Arguments) != 2 || action.Arguments[1].Direction != "out" {
+		t.Errorf("GetInfo = %+v", action)
+	}
+	if v, ok := scpd.StateVariable("UpTime"); !ok || v.DataType != "ui4" {
+		t.Errorf("UpTime = %+v", v)
+	}
+}
+
+// TestPipeline checks that TR-064 requests pass the middleware and metrics of the box client.
+func TestPipeline(t *testing.T) {
+	box := &fakeBox{}
+	srv := httptest.NewServer(box)
+	defer srv.Close()
+
+	client := fritzbox.New(testUser, testPass)
+	var paths []string
+	client.Use(fritzbox.Hooks(func(req *http.Request) { paths = append(paths, req.URL.Path) }, nil))
+	var stats []fritzbox.RequestStats
+	client.SetMetrics(metricsFunc(func(s fritzbox.RequestStats) { stats = append(stats, s) }))
+
+	tc := tr064.New(client)
+	tc.BaseURL = srv.URL
+	if _, err := tc.DeviceInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != box.requests || len(stats) != box.requests {
+		t.Fatalf("%d requests, middleware saw %v, metrics saw %d", box.requests, paths, len(stats))
+	}
+	if s := stats[len(stats)-1]; s.API != "http" || s.StatusCode != http.StatusOK || s.BytesReceived == 0 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+// TestDebugLoggerDigest checks that the digest answer of TR-064 requests is redacted
+// in debug traces.
+func TestDebugLoggerDigest(t *testing.T) {
+	box := &fakeBox{}
+	srv := httptest.NewServer(box)
+	defer srv.Close()
+
+	client := fritzbox.New(testUser, testPass)
+	var auth []string
+	client.Use(fritzbox.Hooks(func(req *http.Request) {
+		if a := req.Header.Get("Authorization"); a != "" {
+			auth = append(auth, a)
+		}
+	}, nil))
+	var buf bytes.Buffer
+	client.Use(fritzbox.DebugLogger(&buf))
+
+	tc := tr064.New(client)
+	tc.BaseURL = srv.URL
+	if _, err := tc.DeviceInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(auth) == 0 {
+		t.Fatal("no authorized request")
+	}
+	response := regexp.MustCompile(`response="([^"]+)"`)
+	for _, a := range auth {
+		m := response.FindStringSubmatch(a)
+		if m == nil {
+			t.Fatalf("no response in %q", a)
+		}
+		if strings.Contains(buf.String(), m[1]) {
+			t.Errorf("digest response %s in log:\n%s", m[1], buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), `response="REDACTED"`) {
+		t.Errorf("log without redacted response:\n%s", buf.String())
+	}
+}
+
+type metricsFunc func(fritzbox.RequestStats)
+
+func (f metricsFunc) ObserveRequest(s fritzbox.RequestStats) { f(s) }
+func (f metricsFunc) ObserveRelogin(error)                   {}
+
+func TestInvalidCredentials(t *testing.T) {
+	tc, _ := newTestClient(t, testUser, "wrong")
+	if _, err := tc.DeviceInfo(context.Background()); !errors.Is(err, fritzbox.ErrInvalidCredentials) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	tc, _ := newTestClient(t, testUser, testPass)
+	hosts, err := tc.Hosts(context.Background())
+	if err != nil {
+		t.Fatalf("hosts: %v", err)
+	}
+	if len(hosts) != 2 || hosts[0].HostName != "laptop" || !hosts[0].Active || hosts[0].LeaseTimeRemaining != 600 || hosts[1].Active {
+		t.Errorf("hosts = %+v", hosts)
+	}
+}
+
+func TestWLAN(t *testing.T) {
+	tc, box := newTestClient(t, testUser, testPass)
+	ctx := context.Background()
+
+	if err := tc.SetWLANEnabled(ctx, 2, true); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if !box.wlanEnabled[1] || box.wlanEnabled[0] {
+		t.Errorf("enabled = %v", box.wlanEnabled)
+	}
+	w, err := tc.WLAN(ctx, 2)
+	if err != nil {
+		t.Fatalf("wlan: %v", err)
+	}
+	if !w.Enabled || w.SSID != "net-2" || w.Channel != 36 {
+		t.Errorf("wlan = %+v", w)
+	}
+
+	if _, err := tc.WLAN(ctx, 3); !errors.Is(err, tr064.ErrNoService) {
+		t.Errorf("third WLAN: %v", err)
+	}
+
+	var fault *tr064.Fault
+	if _, err := tc.WLANAssociations(ctx, 1); !errors.As(err, &fault) || fault.Code != 401 {
+		t.Errorf("unknown action: %v", err)
+	}
+}
+
+func TestCallList(t *testing.T) {
+	tc, _ := newTestClient(t, testUser, testPass)
+	calls, err := tc.CallList(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("call list: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("calls = %+v", calls)
+	}
+
+	in, out := calls[0], calls[1]
+	if in.Type != tr064.CallIncoming || in.Caller != "0301234567" || in.Name != "Doorbell" || in.Duration != 3*time.Minute ||
+		!in.Date.Equal(time.Date(2026, 10, 16, 18, 42, 0, 0, time.Local)) {
+		t.Errorf("incoming = %+v", in)
+	}
+	if out.Type != tr064.CallOutgoing || out.Caller != "987654" || out.Called != "0401111" || out.Duration != 72*time.Minute {
+		t.Errorf("outgoing = %+v", out)
+	}
+}
@@ -0,0 +1,219 @@
+package fritzbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrFingerprintMismatch is returned when the box presents a certificate whose
+// SHA-256 fingerprint differs from the pinned one.
+var ErrFingerprintMismatch = errors.New("certificate fingerprint mismatch")
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a DER encoded certificate
+// as lower-case hex.
+func CertificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts fingerprints with colons or spaces and in any case,
+// as shown by browsers and openssl.
+func normalizeFingerprint(fp string) string {
+	fp = strings.NewReplacer(":", "", " ", "").Replace(fp)
+	return strings.ToLower(fp)
+}
+
+// PinnedTLSConfig returns a TLS configuration that only accepts a box certificate
+// with the given SHA-256 fingerprint. The certificate chain and host name are not
+// verified, since the box's certificate is self-signed; the fingerprint replaces them.
+func PinnedTLSConfig(fingerprint string) *tls.Config {
+	want := normalizeFingerprint(fingerprint)
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			got := CertificateFingerprint(cs.PeerCertificates[0].Raw)
+			if got != want {
+				return fmt.Errorf("%w: box presented %s", ErrFingerprintMismatch, got)
+			}
+			return nil
+		},
+	}
+}
+
+// FingerprintStore persists the certificate fingerprints pinned by TOFUTLSConfig.
+type FingerprintStore interface {
+	// LoadFingerprint returns the fingerprint pinned for host, or "" if there is none.
+	LoadFingerprint(host string) (string, error)
+	// SaveFingerprint pins fingerprint for host.
+	SaveFingerprint(host, fingerprint string) error
+}
+
+// TOFUTLSConfig returns a TLS configuration that trusts the certificate the box
+// presents on first use and pins its fingerprint in store under host, usually the
+// host of BaseUrl. Later connections fail with ErrFingerprintMismatch if the box
+// presents a different certificate.
+//
+// Remove the entry from the store after the box's certificate was renewed on purpose,
+// e.g. after a reset or a change of the MyFRITZ domain.
+func TOFUTLSConfig(store FingerprintStore, host string) *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			got := CertificateFingerprint(cs.PeerCertificates[0].Raw)
+			want, err := store.LoadFingerprint(host)
+			if err != nil {
+				return fmt.Errorf("load fingerprint: %w", err)
+			}
+			if want == "" {
+				return store.SaveFingerprint(host, got)
+			}
+			if normalizeFingerprint(want) != got {
+				return fmt.Errorf("%w: %s presented %s, pinned %s", ErrFingerprintMismatch, host, got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// FileFingerprintStore keeps pinned fingerprints in a file with one
+// "host fingerprint" line per host, similar to ssh's known_hosts.
+type FileFingerprintStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileFingerprintStore returns a store that keeps fingerprints in the file at path.
+func NewFileFingerprintStore(path string) *FileFingerprintStore {
+	return &FileFingerprintStore{Path: path}
+}
+
+// LoadFingerprint reads the fingerprint for host. A missing file is not an error.
+func (f *FileFingerprintStore) LoadFingerprint(host string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	return entries[host], nil
+}
+
+// SaveFingerprint adds or replaces the entry for host.
+func (f *FileFingerprintStore) SaveFingerprint(host, fingerprint string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.read()
+	if err != nil {
+		return err
+	}
+	entries[host] = normalizeFingerprint(fingerprint)
+
+	hosts := make([]string, 0, len(entries))
+	for h := range entries {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	var buf bytes.Buffer
+	for _, h := range hosts {
+		fmt.Fprintf(&buf, "%s %s\n", h, entries[h])
+	}
+	return writeFileAtomic(f.Path, buf.Bytes())
+}
+
+func (f *FileFingerprintStore) read() (map[string]string, error) {
+	entries := make(map[string]string)
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && !strings.HasPrefix(fields[0], "#") {
+			entries[fields[0]] = fields[1]
+		}
+	}
+	return entries, sc.Err()
+}
+
+// FetchFingerprint connects to the box at baseURL and returns the SHA-256 fingerprint
+// of its certificate without verifying it. Use it over a trusted network to obtain
+// the value for PinnedTLSConfig.
+func FetchFingerprint(ctx context.Context, baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("base url must start with https://")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	d := tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("no certificate presented")
+	}
+	return CertificateFingerprint(certs[0].Raw), nil
+}
+
+// SetTLSConfig sets the TLS configuration for HTTPS connections to the box, e.g. one
+// returned by PinnedTLSConfig or TOFUTLSConfig, or one with a custom RootCAs pool.
+// It sets cfg on a copy of the transport of the HTTP client, keeping the other settings
+// of the transport and the client. Returns an error if the client set with
+// SetHTTPClient uses a transport other than *http.Transport.
+// Must be called before Connect().
+func (c *Client) SetTLSConfig(cfg *tls.Config) error {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var client http.Client
+	if c.http != nil {
+		client = *c.http
+	}
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("set tls config: transport %T is not an *http.Transport", t)
+	}
+	transport.TLSClientConfig = cfg
+	client.Transport = transport
+	c.http = &client
+	return nil
+}
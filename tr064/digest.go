@@ -0,0 +1,119 @@
+package tr064
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// digest answers HTTP digest challenges (RFC 2617) as used by TR-064 with MD5 and
+// qop "auth".
+type digest struct {
+	realm     string
+	nonce     string
+	opaque    string
+	qop       string
+	algorithm string
+
+	mu sync.Mutex
+	nc int
+}
+
+// parseChallenge parses a WWW-Authenticate header of the Digest scheme.
+func parseChallenge(header string) (*digest, error) {
+	scheme, params, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Digest") {
+		return nil, fmt.Errorf("unsupported authentication %q", header)
+	}
+
+	d := &digest{}
+	for _, p := range splitParams(params) {
+		k, v, _ := strings.Cut(p, "=")
+		v = strings.Trim(v, `"`)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "realm":
+			d.realm = v
+		case "nonce":
+			d.nonce = v
+		case "opaque":
+			d.opaque = v
+		case "algorithm":
+			d.algorithm = v
+		case "qop":
+			// the box offers "auth" only; pick it from a list if needed
+			for _, q := range strings.Split(v, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					d.qop = "auth"
+				}
+			}
+		}
+	}
+	if d.nonce == "" {
+		return nil, fmt.Errorf("digest challenge without nonce")
+	}
+	if d.algorithm != "" && !strings.EqualFold(d.algorithm, "MD5") {
+		return nil, fmt.Errorf("unsupported digest algorithm %q", d.algorithm)
+	}
+	return d, nil
+}
+
+// splitParams splits comma-separated parameters, keeping commas inside quotes.
+func splitParams(s string) []string {
+	var params []string
+	var quoted bool
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(params, strings.TrimSpace(s[start:]))
+}
+
+// authorize returns the Authorization header for a request.
+func (d *digest) authorize(method, uri, username, password string) string {
+	d.mu.Lock()
+	d.nc++
+	nc := fmt.Sprintf("%08x", d.nc)
+	d.mu.Unlock()
+
+	ha1 := md5hex(username + ":" + d.realm + ":" + password)
+	ha2 := md5hex(method + ":" + uri)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username="%s", realm="%s", nonce="%s", uri="%s"`, username, d.realm, d.nonce, uri)
+	if d.qop == "" {
+		fmt.Fprintf(&sb, `, response="%s"`, md5hex(ha1+":"+d.nonce+":"+ha2))
+	} else {
+		cnonce := randomHex(8)
+		response := md5hex(ha1 + ":" + d.nonce + ":" + nc + ":" + cnonce + ":" + d.qop + ":" + ha2)
+		fmt.Fprintf(&sb, `, qop=%s, nc=%s, cnonce="%s", response="%s"`, d.qop, nc, cnonce, response)
+	}
+	if d.algorithm != "" {
+		fmt.Fprintf(&sb, `, algorithm=%s`, d.algorithm)
+	}
+	if d.opaque != "" {
+		fmt.Fprintf(&sb, `, opaque="%s"`, d.opaque)
+	}
+	return sb.String()
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,172 @@
+package tr064
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallType is the type of a call list entry.
+type CallType int
+
+const (
+	CallIncoming       CallType = 1
+	CallMissed         CallType = 2
+	CallOutgoing       CallType = 3
+	CallActiveIncoming CallType = 9
+	CallRejected       CallType = 10
+	CallActiveOutgoing CallType = 11
+)
+
+func (t CallType) String() string {
+	switch t {
+	case CallIncoming:
+		return "incoming"
+	case CallMissed:
+		return "missed"
+	case CallOutgoing:
+		return "outgoing"
+	case CallActiveIncoming:
+		return "active incoming"
+	case CallRejected:
+		return "rejected"
+	case CallActiveOutgoing:
+		return "active outgoing"
+	default:
+		return fmt.Sprintf("CallType(%d)", int(t))
+	}
+}
+
+// Call is an entry of the call list.
+type Call struct {
+	ID   int
+	Type CallType
+	// Caller is the calling number for incoming calls, the own number for outgoing ones.
+	Caller string
+	// Called is the own number for incoming calls, the called number for outgoing ones.
+	Called string
+	// Name is the phone book name of the other party, if known.
+	Name string
+	// Device is the extension that took or made the call, e.g. "Wohnzimmer".
+	Device   string
+	Port     string
+	Date     time.Time
+	Duration time.Duration
+}
+
+type callEntry struct {
+	ID           int    `xml:"Id"`
+	Type         int    `xml:"Type"`
+	Caller       string `xml:"Caller"`
+	Called       string `xml:"Called"`
+	CallerNumber string `xml:"CallerNumber"`
+	CalledNumber string `xml:"CalledNumber"`
+	Name         string `xml:"Name"`
+	Device       string `xml:"Device"`
+	Port         string `xml:"Port"`
+	Date         string `xml:"Date"`
+	Duration     string `xml:"Duration"`
+}
+
+// CallList returns the calls of the last days, newest first. days <= 0 returns the
+// whole list. The box returns the URL of the list via X_AVM-DE_OnTel:GetCallList;
+// the list itself is fetched from that URL with the session ID it contains.
+func (c *Client) CallList(ctx context.Context, days int) ([]Call, error) {
+	out, err := c.Call(ctx, ServiceOnTel, "GetCallList")
+	if err != nil {
+		return nil, err
+	}
+
+	// the URL names the box by its hostname; use the address we reach it by
+	listURL, err := url.Parse(out["NewCallListURL"])
+	if err != nil {
+		return nil, fmt.Errorf("parse call list url: %w", err)
+	}
+	u, err := c.resolve(listURL.Path)
+	if err != nil {
+		return nil, err
+	}
+	q := listURL.Query()
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.box.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("call list: http %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Calls []callEntry `xml:"Call"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("parse call list: %w", err)
+	}
+
+	calls := make([]Call, 0, len(list.Calls))
+	for _, e := range list.Calls {
+		call := Call{
+			ID:       e.ID,
+			Type:     CallType(e.Type),
+			Caller:   e.Caller,
+			Called:   e.Called,
+			Name:     e.Name,
+			Device:   e.Device,
+			Port:     e.Port,
+			Duration: parseCallDuration(e.Duration),
+		}
+		// outgoing calls name the own number as CallerNumber, incoming ones as CalledNumber
+		if call.Caller == "" {
+			call.Caller = e.CallerNumber
+		}
+		if call.Called == "" {
+			call.Called = e.CalledNumber
+		}
+		if t, err := time.ParseInLocation("02.01.06 15:04", e.Date, time.Local); err == nil {
+			call.Date = t
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// parseCallDuration parses durations in the "h:mm" format of the call list.
+func parseCallDuration(s string) time.Duration {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0
+	}
+	hours, _ := strconv.Atoi(h)
+	minutes, _ := strconv.Atoi(m)
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+}
+
+// PhonebookIDs returns the IDs of the phone books of the box.
+func (c *Client) PhonebookIDs(ctx context.Context) ([]int, error) {
+	out, err := c.Call(ctx, ServiceOnTel, "GetPhonebookList")
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, s := range strings.Split(out["NewPhonebookList"], ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
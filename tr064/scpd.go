@@ -0,0 +1,63 @@
+package tr064
+
+import (
+	"context"
+	"fmt"
+)
+
+// SCPD is the service description of a TR-064 service.
+type SCPD struct {
+	Actions        []Action        `xml:"actionList>action"`
+	StateVariables []StateVariable `xml:"serviceStateTable>stateVariable"`
+}
+
+// Action is an action of a service.
+type Action struct {
+	Name      string     `xml:"name"`
+	Arguments []Argument `xml:"argumentList>argument"`
+}
+
+// Argument is an input or output argument of an action.
+type Argument struct {
+	Name string `xml:"name"`
+	// Direction is "in" or "out".
+	Direction            string `xml:"direction"`
+	RelatedStateVariable string `xml:"relatedStateVariable"`
+}
+
+// StateVariable describes the type of arguments.
+type StateVariable struct {
+	Name          string   `xml:"name"`
+	DataType      string   `xml:"dataType"`
+	DefaultValue  string   `xml:"defaultValue"`
+	AllowedValues []string `xml:"allowedValueList>allowedValue"`
+}
+
+// Action returns the action with the given name.
+func (s *SCPD) Action(name string) (Action, bool) {
+	for _, a := range s.Actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+// StateVariable returns the state variable with the given name.
+func (s *SCPD) StateVariable(name string) (StateVariable, bool) {
+	for _, v := range s.StateVariables {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return StateVariable{}, false
+}
+
+// Describe fetches the service description of svc. It is not cached.
+func (c *Client) Describe(ctx context.Context, svc Service) (*SCPD, error) {
+	var scpd SCPD
+	if err := c.getXML(ctx, svc.SCPDURL, &scpd); err != nil {
+		return nil, fmt.Errorf("get %s: %w", svc.SCPDURL, err)
+	}
+	return &scpd, nil
+}
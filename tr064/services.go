@@ -0,0 +1,234 @@
+package tr064
+
+import (
+	"context"
+	"strconv"
+)
+
+// Service types of the typed calls.
+const (
+	ServiceDeviceInfo               = "urn:dslforum-org:service:DeviceInfo:1"
+	ServiceWANCommonInterfaceConfig = "urn:dslforum-org:service:WANCommonInterfaceConfig:1"
+	ServiceHosts                    = "urn:dslforum-org:service:Hosts:1"
+	ServiceWLANConfiguration        = "urn:dslforum-org:service:WLANConfiguration:1"
+	ServiceOnTel                    = "urn:dslforum-org:service:X_AVM-DE_OnTel:1"
+)
+
+// DeviceInfo is the result of DeviceInfo:GetInfo.
+type DeviceInfo struct {
+	ManufacturerName string
+	ModelName        string
+	Description      string
+	ProductClass     string
+	SerialNumber     string
+	SoftwareVersion  string
+	HardwareVersion  string
+	SpecVersion      string
+	ProvisioningCode string
+	// UpTime is the time since the last reboot in seconds.
+	UpTime int64
+	// DeviceLog holds the most recent entries of the event log, newest first.
+	DeviceLog string
+}
+
+// DeviceInfo returns model, firmware and uptime of the box.
+func (c *Client) DeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	out, err := c.Call(ctx, ServiceDeviceInfo, "GetInfo")
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceInfo{
+		ManufacturerName: out["NewManufacturerName"],
+		ModelName:        out["NewModelName"],
+		Description:      out["NewDescription"],
+		ProductClass:     out["NewProductClass"],
+		SerialNumber:     out["NewSerialNumber"],
+		SoftwareVersion:  out["NewSoftwareVersion"],
+		HardwareVersion:  out["NewHardwareVersion"],
+		SpecVersion:      out["NewSpecVersion"],
+		ProvisioningCode: out["NewProvisioningCode"],
+		UpTime:           parseInt(out["NewUpTime"]),
+		DeviceLog:        out["NewDeviceLog"],
+	}, nil
+}
+
+// WANLink is the result of WANCommonInterfaceConfig:GetCommonLinkProperties.
+type WANLink struct {
+	// AccessType is e.g. "DSL", "Ethernet" or "X_AVM-DE_Fiber".
+	AccessType string
+	// UpstreamMaxBitRate and DownstreamMaxBitRate are the line rates in bit/s.
+	UpstreamMaxBitRate   int64
+	DownstreamMaxBitRate int64
+	// LinkStatus is "Up", "Down" or "Initializing".
+	LinkStatus string
+}
+
+// WANLink returns the type, state and line rates of the WAN connection.
+func (c *Client) WANLink(ctx context.Context) (*WANLink, error) {
+	out, err := c.Call(ctx, ServiceWANCommonInterfaceConfig, "GetCommonLinkProperties")
+	if err != nil {
+		return nil, err
+	}
+	return &WANLink{
+		AccessType:           out["NewWANAccessType"],
+		UpstreamMaxBitRate:   parseInt(out["NewLayer1UpstreamMaxBitRate"]),
+		DownstreamMaxBitRate: parseInt(out["NewLayer1DownstreamMaxBitRate"]),
+		LinkStatus:           out["NewPhysicalLinkStatus"],
+	}, nil
+}
+
+// WANTraffic is the result of WANCommonInterfaceConfig:GetAddonInfos.
+type WANTraffic struct {
+	// SendRate and ReceiveRate are the current rates in bytes/s.
+	SendRate    int64
+	ReceiveRate int64
+	// TotalBytesSent and TotalBytesReceived count since the last reconnect.
+	TotalBytesSent     int64
+	TotalBytesReceived int64
+}
+
+// WANTraffic returns the current rates and byte counters of the WAN connection.
+func (c *Client) WANTraffic(ctx context.Context) (*WANTraffic, error) {
+	out, err := c.Call(ctx, ServiceWANCommonInterfaceConfig, "GetAddonInfos")
+	if err != nil {
+		return nil, err
+	}
+
+	// the 32 bit counters wrap at 4 GiB; newer firmware adds 64 bit ones
+	sent, received := out["NewX_AVM_DE_TotalBytesSent64"], out["NewX_AVM_DE_TotalBytesReceived64"]
+	if sent == "" {
+		sent, received = out["NewTotalBytesSent"], out["NewTotalBytesReceived"]
+	}
+	return &WANTraffic{
+		SendRate:           parseInt(out["NewByteSendRate"]),
+		ReceiveRate:        parseInt(out["NewByteReceiveRate"]),
+		TotalBytesSent:     parseInt(sent),
+		TotalBytesReceived: parseInt(received),
+	}, nil
+}
+
+// Host is a network device known to the box, from Hosts:GetGenericHostEntry.
+type Host struct {
+	IPAddress string
+	// AddressSource is "DHCP" or "Static".
+	AddressSource string
+	// LeaseTimeRemaining is in seconds.
+	LeaseTimeRemaining int64
+	MACAddress         string
+	// InterfaceType is e.g. "Ethernet" or "802.11".
+	InterfaceType string
+	Active        bool
+	HostName      string
+}
+
+func hostFromArgs(out map[string]string) Host {
+	return Host{
+		IPAddress:          out["NewIPAddress"],
+		AddressSource:      out["NewAddressSource"],
+		LeaseTimeRemaining: parseInt(out["NewLeaseTimeRemaining"]),
+		MACAddress:         out["NewMACAddress"],
+		InterfaceType:      out["NewInterfaceType"],
+		Active:             out["NewActive"] == "1",
+		HostName:           out["NewHostName"],
+	}
+}
+
+// Hosts returns all hosts known to the box, including inactive ones.
+// It makes one call per host; the list may change between calls.
+func (c *Client) Hosts(ctx context.Context) ([]Host, error) {
+	out, err := c.Call(ctx, ServiceHosts, "GetHostNumberOfEntries")
+	if err != nil {
+		return nil, err
+	}
+	n := int(parseInt(out["NewHostNumberOfEntries"]))
+
+	hosts := make([]Host, 0, n)
+	for i := 0; i < n; i++ {
+		out, err := c.Call(ctx, ServiceHosts, "GetGenericHostEntry", Arg{"NewIndex", strconv.Itoa(i)})
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, hostFromArgs(out))
+	}
+	return hosts, nil
+}
+
+// Host returns the host with the given MAC address.
+func (c *Client) Host(ctx context.Context, mac string) (*Host, error) {
+	out, err := c.Call(ctx, ServiceHosts, "GetSpecificHostEntry", Arg{"NewMACAddress", mac})
+	if err != nil {
+		return nil, err
+	}
+	h := hostFromArgs(out)
+	h.MACAddress = mac
+	return &h, nil
+}
+
+// WLAN is the result of WLANConfiguration:GetInfo.
+type WLAN struct {
+	Enabled bool
+	// Status is "Up", "Disabled" or "Error".
+	Status     string
+	SSID       string
+	BSSID      string
+	Channel    int
+	Standard   string
+	BeaconType string
+}
+
+// WLAN returns the state of the n-th wireless network, starting at 1. On most boxes
+// 1 is 2.4 GHz, 2 is 5 GHz and the last one is the guest network.
+func (c *Client) WLAN(ctx context.Context, n int) (*WLAN, error) {
+	svc, err := c.Service(ctx, ServiceWLANConfiguration, n)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.CallService(ctx, svc, "GetInfo")
+	if err != nil {
+		return nil, err
+	}
+	return &WLAN{
+		Enabled:    out["NewEnable"] == "1",
+		Status:     out["NewStatus"],
+		SSID:       out["NewSSID"],
+		BSSID:      out["NewBSSID"],
+		Channel:    int(parseInt(out["NewChannel"])),
+		Standard:   out["NewStandard"],
+		BeaconType: out["NewBeaconType"],
+	}, nil
+}
+
+// SetWLANEnabled switches the n-th wireless network on or off.
+func (c *Client) SetWLANEnabled(ctx context.Context, n int, enabled bool) error {
+	svc, err := c.Service(ctx, ServiceWLANConfiguration, n)
+	if err != nil {
+		return err
+	}
+	_, err = c.CallService(ctx, svc, "SetEnable", Arg{"NewEnable", boolArg(enabled)})
+	return err
+}
+
+// WLANAssociations returns the number of clients connected to the n-th wireless network.
+func (c *Client) WLANAssociations(ctx context.Context, n int) (int, error) {
+	svc, err := c.Service(ctx, ServiceWLANConfiguration, n)
+	if err != nil {
+		return 0, err
+	}
+	out, err := c.CallService(ctx, svc, "GetTotalAssociations")
+	if err != nil {
+		return 0, err
+	}
+	return int(parseInt(out["NewTotalAssociations"])), nil
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func boolArg(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,315 @@
+// Package tr064 provides access to the TR-064 SOAP interface of the FRITZ!Box.
+// Unlike data.lua, TR-064 is documented by AVM and stable across FRITZ!OS versions.
+// API documentation: https://avm.de/service/schnittstellen/
+package tr064
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ByteSizedMarius/go-fritzbox-api/v2"
+)
+
+const (
+	descPath  = "/tr64desc.xml"
+	httpPort  = "49000"
+	httpsPort = "49443"
+)
+
+// ErrNoService is returned when the box does not offer a service.
+var ErrNoService = errors.New("service not offered")
+
+// Client calls TR-064 actions on the box of a fritzbox.Client, using its credentials.
+// Requests are sent with fritzbox.Client.Do, so they pass its middleware, rate limiter,
+// retry policy and metrics. TR-064 has its own authentication, so the fritzbox.Client
+// does not need to be connected.
+//
+//	tc := tr064.New(client)
+//	info, err := tc.DeviceInfo(ctx)
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	// BaseURL is the TR-064 endpoint. New derives it from the URL of the
+	// fritzbox.Client: port 49000 for http, 49443 for https.
+	BaseURL string
+
+	box *fritzbox.Client
+
+	mu       sync.Mutex
+	services []Service
+	auth     *digest
+}
+
+// New returns a TR-064 client for the box of c.
+func New(c *fritzbox.Client) *Client {
+	base := c.URL()
+
+	tc := &Client{box: c}
+	if u, err := url.Parse(base); err == nil {
+		port := httpPort
+		if u.Scheme == "https" {
+			port = httpsPort
+		}
+		tc.BaseURL = u.Scheme + "://" + net.JoinHostPort(u.Hostname(), port)
+	}
+	return tc
+}
+
+// Service is a TR-064 service listed in tr64desc.xml.
+type Service struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+	SCPDURL     string `xml:"SCPDURL"`
+}
+
+type device struct {
+	Services []Service `xml:"serviceList>service"`
+	Devices  []device  `xml:"deviceList>device"`
+}
+
+func (d device) flatten() []Service {
+	services := d.Services
+	for _, sub := range d.Devices {
+		services = append(services, sub.flatten()...)
+	}
+	return services
+}
+
+// Services returns all services of the box in the order of tr64desc.xml.
+// The description is fetched once.
+func (c *Client) Services(ctx context.Context) ([]Service, error) {
+	c.mu.Lock()
+	services := c.services
+	c.mu.Unlock()
+	if services != nil {
+		return services, nil
+	}
+
+	var desc struct {
+		Device device `xml:"device"`
+	}
+	if err := c.getXML(ctx, descPath, &desc); err != nil {
+		return nil, fmt.Errorf("get description: %w", err)
+	}
+	services = desc.Device.flatten()
+
+	c.mu.Lock()
+	c.services = services
+	c.mu.Unlock()
+	return services, nil
+}
+
+// Service returns the n-th service (starting at 1) of the given type, e.g.
+// "urn:dslforum-org:service:WLANConfiguration:1" with n = 2 for the 5 GHz network.
+func (c *Client) Service(ctx context.Context, serviceType string, n int) (Service, error) {
+	services, err := c.Services(ctx)
+	if err != nil {
+		return Service{}, err
+	}
+	for _, s := range services {
+		if s.ServiceType == serviceType {
+			if n--; n == 0 {
+				return s, nil
+			}
+		}
+	}
+	return Service{}, fmt.Errorf("%w: %s", ErrNoService, serviceType)
+}
+
+// Fault is a SOAP fault returned by an action, with the UPnP error code.
+// AVM uses 401 for invalid arguments, 402 for invalid values and 820 for
+// internal errors.
+type Fault struct {
+	Action      string
+	Code        int
+	Description string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%s: UPnP error %d: %s", f.Action, f.Code, f.Description)
+}
+
+// Arg is an input argument of an action. Arguments are sent in order.
+type Arg struct {
+	Name  string
+	Value string
+}
+
+// Call invokes action of the first service of serviceType and returns the output
+// arguments by name.
+func (c *Client) Call(ctx context.Context, serviceType, action string, args ...Arg) (map[string]string, error) {
+	svc, err := c.Service(ctx, serviceType, 1)
+	if err != nil {
+		return nil, err
+	}
+	return c.CallService(ctx, svc, action, args...)
+}
+
+// CallService is like Call for a specific service, e.g. one returned by Service.
+func (c *Client) CallService(ctx context.Context, svc Service, action string, args ...Arg) (map[string]string, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, svc.ServiceType)
+	for _, a := range args {
+		fmt.Fprintf(&body, "<%s>", a.Name)
+		if err := xml.EscapeText(&body, []byte(a.Value)); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "</%s>", a.Name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	header := http.Header{
+		"Content-Type": {`text/xml; charset="utf-8"`},
+		"Soapaction":   {svc.ServiceType + "#" + action},
+	}
+	resp, err := c.do(ctx, http.MethodPost, svc.ControlURL, header, body.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: read response: %w", action, err)
+	}
+	if resp.StatusCode == http.StatusInternalServerError {
+		if f := parseFault(b); f != nil {
+			f.Action = action
+			return nil, f
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: http %d", action, resp.StatusCode)
+	}
+
+	var env struct {
+		Body struct {
+			Response struct {
+				Args []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("%s: parse response: %w", action, err)
+	}
+	out := make(map[string]string, len(env.Body.Response.Args))
+	for _, a := range env.Body.Response.Args {
+		out[a.XMLName.Local] = a.Value
+	}
+	return out, nil
+}
+
+func parseFault(b []byte) *Fault {
+	var env struct {
+		Fault struct {
+			Error struct {
+				Code        int    `xml:"errorCode"`
+				Description string `xml:"errorDescription"`
+			} `xml:"detail>UPnPError"`
+		} `xml:"Body>Fault"`
+	}
+	if xml.Unmarshal(b, &env) != nil || env.Fault.Error.Code == 0 {
+		return nil
+	}
+	return &Fault{Code: env.Fault.Error.Code, Description: env.Fault.Error.Description}
+}
+
+// getXML fetches a document from the box and decodes it into target.
+func (c *Client) getXML(ctx context.Context, path string, target any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http %d", resp.StatusCode)
+	}
+	return xml.NewDecoder(resp.Body).Decode(target)
+}
+
+// do sends a request, answering a digest challenge with the credentials of the box
+// client. The challenge is kept for later requests, so usually one round trip is enough.
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	u, err := c.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		c.mu.Lock()
+		auth := c.auth
+		c.mu.Unlock()
+		if auth != nil {
+			user, pass, err := c.box.Credentials(ctx)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", auth.authorize(method, u.RequestURI(), user, pass))
+		}
+		return c.box.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// new or stale nonce
+	resp.Body.Close()
+	auth, err := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.auth = auth
+	c.mu.Unlock()
+
+	resp, err = send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fritzbox.ErrInvalidCredentials
+	}
+	return resp, nil
+}
+
+func (c *Client) resolve(path string) (*url.URL, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	rel, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse path: %w", err)
+	}
+	return base.ResolveReference(rel), nil
+}
@@ -0,0 +1,213 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors of the second factor confirmation that hardened boxes require for sensitive
+// changes. The REST API reports the first three with the codes 3008 to 3010.
+var (
+	ErrTwoFactorRequired = errors.New("second factor confirmation required")
+	ErrTwoFactorBusy     = errors.New("second factor confirmation already in progress")
+	ErrTwoFactorBlocked  = errors.New("second factor confirmation blocked")
+	ErrTwoFactorFailed   = errors.New("second factor not confirmed")
+)
+
+// REST error codes of the second factor confirmation.
+const (
+	restCodeTwoFactorNeeded  = 3008
+	restCodeTwoFactorBusy    = 3009
+	restCodeTwoFactorBlocked = 3010
+)
+
+// twoFactorPoll is the interval at which Wait asks the box for the confirmation.
+const twoFactorPoll = time.Second
+
+// TwoFactorMethod is a way to confirm a challenge.
+type TwoFactorMethod string
+
+const (
+	// TwoFactorButton is pressing any button on the box.
+	TwoFactorButton TwoFactorMethod = "button"
+	// TwoFactorDTMF is dialing TwoFactorChallenge.DTMFCode on a phone connected to the box.
+	TwoFactorDTMF TwoFactorMethod = "dtmf"
+)
+
+// TwoFactorChallenge is a started second factor confirmation. It is bound to the
+// session it was started in; the confirmation allows the next protected change.
+type TwoFactorChallenge struct {
+	// Methods are the ways offered to confirm the challenge.
+	Methods []TwoFactorMethod
+	// DTMFCode is the code to dial if TwoFactorDTMF is offered, e.g. "*1234567#".
+	DTMFCode string
+
+	client *Client
+}
+
+// Offers reports whether m is one of the offered methods.
+func (ch *TwoFactorChallenge) Offers(m TwoFactorMethod) bool {
+	for _, o := range ch.Methods {
+		if o == m {
+			return true
+		}
+	}
+	return false
+}
+
+// twoFactorResponse is the answer of twofactor.lua. err is 0 on success, 1 if a
+// challenge is already running and 2 if confirmations are blocked.
+type twoFactorResponse struct {
+	Err  int `json:"err"`
+	Data struct {
+		Methods []string `json:"methods"`
+		Active  bool     `json:"active"`
+		Done    bool     `json:"done"`
+	} `json:"data"`
+}
+
+// StartTwoFactor starts a second factor confirmation, as the web interface does before
+// a protected change. Show the offered methods to the user, call Wait and repeat the
+// change once it returns nil.
+func (c *Client) StartTwoFactor(ctx context.Context) (*TwoFactorChallenge, error) {
+	c = c.root()
+	resp, err := c.twoFactorRequest(ctx, "tfa_start")
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &TwoFactorChallenge{client: c}
+	// methods are named like "button" or "dtmf;*1234567#"
+	for _, m := range resp.Data.Methods {
+		name, code, _ := strings.Cut(m, ";")
+		ch.Methods = append(ch.Methods, TwoFactorMethod(name))
+		if TwoFactorMethod(name) == TwoFactorDTMF {
+			ch.DTMFCode = code
+		}
+	}
+	if len(ch.Methods) == 0 {
+		return nil, errors.New("start second factor: no methods offered")
+	}
+	return ch, nil
+}
+
+// Done reports whether the challenge was confirmed. It returns an error wrapping
+// ErrTwoFactorFailed once the box no longer waits for the confirmation, e.g. because
+// it timed out.
+func (ch *TwoFactorChallenge) Done(ctx context.Context) (bool, error) {
+	resp, err := ch.client.twoFactorRequest(ctx, "tfa_active")
+	if err != nil {
+		return false, err
+	}
+	if resp.Data.Done {
+		return true, nil
+	}
+	if !resp.Data.Active {
+		return false, fmt.Errorf("%w: challenge expired", ErrTwoFactorFailed)
+	}
+	return false, nil
+}
+
+// Wait polls the box until the challenge is confirmed, fails or ctx is done.
+func (ch *TwoFactorChallenge) Wait(ctx context.Context) error {
+	t := time.NewTicker(twoFactorPoll)
+	defer t.Stop()
+	for {
+		done, err := ch.Done(ctx)
+		if done || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// twoFactorRequest sends action to twofactor.lua.
+func (c *Client) twoFactorRequest(ctx context.Context, action string) (*twoFactorResponse, error) {
+	resp, err := c.AhaRequestContext(ctx, http.MethodPost, "twofactor.lua", Values{"sid": c.SID(), action: ""})
+	if err != nil {
+		return nil, fmt.Errorf("second factor: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("second factor: read response: %w", err)
+	}
+	var r twoFactorResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("second factor: parse response: %w", err)
+	}
+
+	switch r.Err {
+	case 0:
+		return &r, nil
+	case 1:
+		return nil, ErrTwoFactorBusy
+	case 2:
+		return nil, ErrTwoFactorBlocked
+	default:
+		return nil, fmt.Errorf("second factor: error %d", r.Err)
+	}
+}
+
+// TwoFactorHandler shows a started challenge to the user, e.g. "press a button on the
+// box or dial *1234567#". The client waits for the confirmation after it returns;
+// returning an error aborts the change.
+type TwoFactorHandler func(ctx context.Context, ch *TwoFactorChallenge) error
+
+// SetTwoFactorHandler makes the client confirm REST requests rejected with error 3008
+// itself: it starts a challenge, calls h, waits for the confirmation and repeats the
+// request. Pass nil to return the rejection to the caller (the default).
+func (c *Client) SetTwoFactorHandler(h TwoFactorHandler) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.twoFactor = h
+}
+
+// ConfirmTwoFactor starts a challenge, calls h and waits for the confirmation.
+// Use it to repeat a change that failed with ErrTwoFactorRequired:
+//
+//	err := unsafe.SetIP(client, uid, ip, true)
+//	if errors.Is(err, fritzbox.ErrTwoFactorRequired) {
+//	    if err = client.ConfirmTwoFactor(ctx, prompt); err == nil {
+//	        err = unsafe.SetIP(client, uid, ip, true)
+//	    }
+//	}
+func (c *Client) ConfirmTwoFactor(ctx context.Context, h TwoFactorHandler) error {
+	ch, err := c.StartTwoFactor(ctx)
+	if err != nil {
+		return err
+	}
+	if err := h(ctx, ch); err != nil {
+		return err
+	}
+	return ch.Wait(ctx)
+}
+
+// TwoFactorError returns the error matching the second factor codes in a REST error
+// body, or nil if it has none. Callers of RestRequest use it to return a checkable
+// error instead of the plain status.
+func TwoFactorError(body []byte) error {
+	for _, code := range restErrorCodes(body) {
+		switch code {
+		case restCodeTwoFactorNeeded:
+			return ErrTwoFactorRequired
+		case restCodeTwoFactorBusy:
+			return ErrTwoFactorBusy
+		case restCodeTwoFactorBlocked:
+			return ErrTwoFactorBlocked
+		}
+	}
+	return nil
+}
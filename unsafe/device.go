@@ -16,6 +16,9 @@ func SetName(c *fritzbox.Client, deviceUID string, newName string) error {
 	if err != nil {
 		return err
 	}
+	if err := fritzbox.TwoFactorError(respBody); err != nil {
+		return fmt.Errorf("failed to set name: %w", err)
+	}
 	if statusCode != http.StatusOK {
 		return fmt.Errorf("failed to set name: HTTP %d: %s", statusCode, string(respBody))
 	}
@@ -34,6 +37,9 @@ func SetName(c *fritzbox.Client, deviceUID string, newName string) error {
 //   - IP is outside the DHCP pool range configured on the router
 //   - IP is a broadcast address (e.g., .255)
 //   - IP conflicts with internal reservations not visible via GetAllDevicesREST
+//
+// Boxes that require a second factor for changes reject it with an error wrapping
+// fritzbox.ErrTwoFactorRequired, unless the client has a TwoFactorHandler.
 func SetIP(c *fritzbox.Client, deviceUID string, ip string, static bool) error {
 	staticDHCP := "0"
 	if static {
@@ -49,6 +55,9 @@ func SetIP(c *fritzbox.Client, deviceUID string, ip string, static bool) error {
 	if err != nil {
 		return err
 	}
+	if err := fritzbox.TwoFactorError(respBody); err != nil {
+		return fmt.Errorf("failed to set IP: %w", err)
+	}
 	if statusCode != http.StatusOK {
 		return fmt.Errorf("failed to set IP: HTTP %d: %s", statusCode, string(respBody))
 	}
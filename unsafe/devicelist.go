@@ -191,6 +191,9 @@ func GetMeshTopology(c *fritzbox.Client) (mt MeshTopology, err error) {
 
 	// remove empty conninfo array, otherwise json.Unmarshal will fail (inconsistent types)
 	body := strings.ReplaceAll(resp, ",\"conninfo\":[]", "")
+	if n := strings.Count(resp, ",\"conninfo\":[]"); n > 0 {
+		c.Logger().Debug("removed empty conninfo arrays", "page", "homeNet", "count", n)
+	}
 
 	r := struct {
 		Data struct {
@@ -214,6 +217,8 @@ func GetMeshTopology(c *fritzbox.Client) (mt MeshTopology, err error) {
 		}
 		if d.Nameinfo.Name != "" && d.UID != "" {
 			mt.Devices = append(mt.Devices, d)
+		} else {
+			c.Logger().Debug("skipping mesh device without name or uid", "uid", d.UID)
 		}
 	}
 
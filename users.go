@@ -0,0 +1,55 @@
+package fritzbox
+
+import (
+	"context"
+	"net/http"
+)
+
+// User is a FRITZ!Box user as listed by login_sid.lua before authentication.
+type User struct {
+	Name string
+	// Last is true for the user that logged in most recently.
+	Last bool
+}
+
+// ListUsers returns the users the box at baseURL offers for login, without
+// authenticating. Boxes configured for password-only login return no users.
+func ListUsers(baseURL string) ([]User, error) {
+	return ListUsersContext(context.Background(), baseURL)
+}
+
+// ListUsersContext is like ListUsers but uses ctx for the request.
+func ListUsersContext(ctx context.Context, baseURL string) ([]User, error) {
+	c := &Client{BaseUrl: baseURL}
+	if err := c.initBase(); err != nil {
+		return nil, err
+	}
+
+	var resp sessionResponse
+	if err := c.loginRequest(ctx, http.MethodGet, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		users = append(users, User{Name: u.Name, Last: u.Last == 1})
+	}
+	return users, nil
+}
+
+// SetUseLastUser makes Connect log in as the user the box reports as last used
+// when Username is empty, as the FRITZ!Box web UI does. This helps with boxes
+// that only have the auto-generated fritzXXXX user. LoginUser returns the chosen
+// name after login.
+func (c *Client) SetUseLastUser(enabled bool) {
+	c = c.root()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.useLastUser = enabled
+}
+
+func (c *Client) usesLastUser() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.useLastUser
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"math"
 )
 
@@ -14,7 +13,7 @@ func ValueFromJsonPath(body string, pathKeys []string) (v map[string]interface{}
 	if err != nil {
 		var e *json.SyntaxError
 		if errors.As(err, &e) {
-			log.Printf("syntax error at byte offset %d", e.Offset)
+			err = fmt.Errorf("syntax error at byte offset %d: %w", e.Offset, err)
 		}
 		return
 	}
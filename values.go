@@ -19,3 +19,13 @@ func (v Values) URLValues() url.Values {
 	}
 	return uv
 }
+
+// with returns a copy of v with key set to value.
+func (v Values) with(key, value string) Values {
+	cp := make(Values, len(v)+1)
+	for k, val := range v {
+		cp[k] = val
+	}
+	cp[key] = value
+	return cp
+}